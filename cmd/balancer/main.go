@@ -10,14 +10,23 @@ import (
 
 	"github.com/ritikchawla/load-balancer/internal/balancer"
 	"github.com/ritikchawla/load-balancer/internal/config"
+	"github.com/ritikchawla/load-balancer/internal/tracing"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to configuration file")
+
+	// Command line overrides take precedence over environment and file
+	var overrides config.Overrides
+	flag.IntVar(&overrides.Port, "port", 0, "listen port (overrides "+config.EnvPort+")")
+	flag.DurationVar(&overrides.HealthCheckInterval, "health-interval", 0, "health check interval (overrides "+config.EnvHealthCheckInterval+")")
+	flag.Float64Var(&overrides.PhiThreshold, "phi-threshold", 0, "phi-accrual failure threshold (overrides "+config.EnvPhiThreshold+")")
+	flag.StringVar(&overrides.Strategy, "strategy", "", "backend selection strategy (overrides "+config.EnvStrategy+")")
+	flag.StringVar(&overrides.Backends, "backends", "", "comma-separated host:port:weight list (overrides "+config.EnvBackends+")")
 	flag.Parse()
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.LoadWithOverrides(*configPath, overrides)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -26,6 +35,17 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Error shutting down tracing: %v", err)
+		}
+	}()
+
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// handleReady reports whether enough backends are healthy to serve traffic.
+// Unlike /health, which only shows the process is alive, it returns 503 so
+// orchestrators stop routing to this instance when it has no usable backends.
+func (b *balancer) handleReady(w http.ResponseWriter, r *http.Request) {
+	healthy := b.healthyCount()
+	required := b.cfg.Balancer.MinHealthyBackends
+
+	if healthy < required {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "NOT READY: %d/%d healthy backends\n", healthy, required)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "READY: %d healthy backends\n", healthy)
+}
+
+// healthyCount returns the number of healthy backends
+func (b *balancer) healthyCount() int {
+	count := 0
+	b.backends.Range(func(_, value any) bool {
+		if value.(*backend).health.Load() {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// handleHealthDetail serves the health checker's per-backend phi and timing
+// statistics as JSON
+func (b *balancer) handleHealthDetail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b.health.Snapshot()); err != nil {
+		log.Printf("Error encoding health detail: %v", err)
+	}
+}
+
+// handlePool serves the connection pool statistics as JSON
+func (b *balancer) handlePool(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b.pool.Stats()); err != nil {
+		log.Printf("Error encoding pool stats: %v", err)
+	}
+}
+
+// handleMetrics serves balancer metrics in the Prometheus text format
+func (b *balancer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := b.pool.Stats()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "lb_connections_in_flight %d\n", b.inFlight.Load())
+	fmt.Fprintf(w, "lb_pool_active_connections %d\n", stats.Active)
+	fmt.Fprintf(w, "lb_pool_dials_total %d\n", stats.Dials)
+	fmt.Fprintf(w, "lb_pool_reuses_total %d\n", stats.Reuses)
+	fmt.Fprintf(w, "lb_pool_closes_total %d\n", stats.Closes)
+	fmt.Fprintf(w, "lb_pool_idle_timeouts_total %d\n", stats.IdleTimeouts)
+
+	addrs := make([]string, 0, len(stats.Idle))
+	for addr := range stats.Idle {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(w, "lb_pool_idle_connections{backend=%q} %d\n", addr, stats.Idle[addr])
+	}
+}
@@ -0,0 +1,96 @@
+package balancer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ritikchawla/load-balancer/internal/connpool"
+	"github.com/ritikchawla/load-balancer/internal/health"
+)
+
+// adminGet serves a GET of path with handler and returns the recorded
+// response
+func adminGet(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestAdminPoolStats(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+	for range 3 {
+		if _, err := b.pool.Get(echo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stats connpool.Stats
+	if err := json.NewDecoder(adminGet(b.handlePool, "/pool").Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Dials < 3 {
+		t.Errorf("/pool reports %d dials after 3 connections", stats.Dials)
+	}
+
+	body := adminGet(b.handleMetrics, "/metrics").Body.String()
+	for _, metric := range []string{
+		"lb_pool_active_connections ",
+		"lb_pool_dials_total ",
+		"lb_pool_reuses_total ",
+		"lb_pool_closes_total ",
+		"lb_pool_idle_timeouts_total ",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("/metrics lacks %s", metric)
+		}
+	}
+}
+
+func TestAdminReadiness(t *testing.T) {
+	backends := []string{"127.0.0.1:9101", "127.0.0.1:9102"}
+	cfg := loadTestConfig(t, testYAML("", backends...))
+	cfg.Balancer.MinHealthyBackends = 2
+	b := newTestBalancer(t, cfg)
+
+	steps := []struct {
+		name    string
+		healthy map[string]bool
+		want    int
+	}{
+		{"all healthy", map[string]bool{backends[0]: true, backends[1]: true}, http.StatusOK},
+		{"one down", map[string]bool{backends[1]: false}, http.StatusServiceUnavailable},
+		{"all down", map[string]bool{backends[0]: false}, http.StatusServiceUnavailable},
+		{"recovered", map[string]bool{backends[0]: true, backends[1]: true}, http.StatusOK},
+	}
+	for _, step := range steps {
+		for addr, healthy := range step.healthy {
+			b.updateBackendHealth(addr, healthy)
+		}
+		if got := adminGet(b.handleReady, "/ready").Code; got != step.want {
+			t.Errorf("%s: /ready = %d, want %d", step.name, got, step.want)
+		}
+	}
+}
+
+func TestAdminHealthDetail(t *testing.T) {
+	backends := []string{"127.0.0.1:9101", "127.0.0.1:9102"}
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", backends...)))
+
+	rec := adminGet(b.handleHealthDetail, "/health/detail")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var detail map[string]health.HealthStats
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range backends {
+		if _, ok := detail[addr]; !ok {
+			t.Errorf("/health/detail lacks %s: %v", addr, detail)
+		}
+	}
+}
@@ -2,23 +2,40 @@ package balancer
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ritikchawla/load-balancer/internal/config"
 	"github.com/ritikchawla/load-balancer/internal/connpool"
 	"github.com/ritikchawla/load-balancer/internal/hashing"
 	"github.com/ritikchawla/load-balancer/internal/health"
+	"github.com/ritikchawla/load-balancer/internal/netutil"
+	"github.com/ritikchawla/load-balancer/internal/tracing"
 )
 
 // LoadBalancer represents the main load balancer interface
 type LoadBalancer interface {
 	Start(context.Context) error
 	Shutdown(context.Context) error
+
+	// RemoveBackend removes a backend immediately, severing its connections
+	RemoveBackend(host string, port int) error
+	// RemoveBackendGracefully stops new connections to a backend and lets
+	// in-flight ones finish until the timeout, then force-closes the rest
+	// and returns how many were dropped
+	RemoveBackendGracefully(host string, port int, timeout time.Duration) (int, error)
 }
 
 // balancer implements the LoadBalancer interface
@@ -27,48 +44,125 @@ type balancer struct {
 	listener net.Listener
 	pool     *connpool.Pool
 	hasher   *hashing.ConsistentHasher
+	strategy strategy
 	health   *health.Checker
 	backends sync.Map // map[string]*backend
 	mu       sync.RWMutex
+
+	// Concurrent connection limiting; slots is nil when unlimited
+	slots    chan struct{}
+	inFlight atomic.Int64
+
+	// Whether backend response latency is sampled, for the strategies that
+	// pick by it
+	sampleLatency bool
+
+	// Pooled copy buffers; nil when the default copy path is used
+	buffers *sync.Pool
 }
 
 // backend represents a backend server
 type backend struct {
 	host   string
 	port   int
-	weight int
-	health bool
+	weight atomic.Int64 // zero drains the backend of new connections
+	backup bool         // only used when no primary backend is eligible
+	health atomic.Bool
+	active atomic.Int64
+
+	// EWMA of response latency, from the first write to the first byte
+	// back, in nanoseconds; zero until sampled
+	latency atomic.Int64
+
+	// TLS settings for the backend leg; nil for plain TCP
+	tls *tls.Config
+
+	// Live backend connections, tracked so removal can force-close them
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+	removed atomic.Bool
+
+	// Slow start ramp window and the time the backend last became healthy
+	// (unix nanoseconds, zero when not ramping)
+	slowStart    time.Duration
+	healthySince atomic.Int64
+}
+
+// addr returns the host:port address of the backend
+func (be *backend) addr() string {
+	return netutil.JoinHostPort(be.host, be.port)
 }
 
 // New creates a new load balancer instance
 func New(cfg *config.Config) (LoadBalancer, error) {
+	return NewWithOptions(cfg, Options{})
+}
+
+// NewWithOptions creates a new load balancer instance customized by opts
+func NewWithOptions(cfg *config.Config, opts Options) (LoadBalancer, error) {
 	b := &balancer{
 		cfg: cfg,
 	}
 
 	// Initialize connection pool
-	pool, err := connpool.New(cfg.Pool)
+	pool, err := connpool.New(cfg.Pool, b.dialBackend)
 	if err != nil {
 		return nil, fmt.Errorf("creating connection pool: %w", err)
 	}
 	b.pool = pool
 
+	// Initialize concurrent connection limit
+	if cfg.Balancer.MaxConcurrentConnections > 0 {
+		b.slots = make(chan struct{}, cfg.Balancer.MaxConcurrentConnections)
+	}
+
+	// Initialize proxy copy buffers
+	if size := cfg.Proxy.BufferSize; size > 0 {
+		b.buffers = newBufferPool(size)
+	}
+
 	// Initialize consistent hasher
 	b.hasher = hashing.New()
 
+	// Initialize backend selection strategy; a custom selector takes
+	// precedence over the configured one
+	if opts.Selector != nil {
+		b.strategy = &selectorStrategy{fn: opts.Selector}
+	} else {
+		strat, err := newStrategy(cfg.Balancer.Strategy, b.hasher)
+		if err != nil {
+			return nil, fmt.Errorf("creating strategy: %w", err)
+		}
+		b.strategy = strat
+		b.sampleLatency = cfg.Balancer.Strategy == config.StrategyLeastResponseTime ||
+			cfg.Balancer.Strategy == config.StrategyWeightedLeastResponseTime
+	}
+
 	// Initialize health checker
-	b.health = health.New(cfg.Balancer.HealthCheckInterval, cfg.Balancer.FailureThreshold)
+	b.health = health.New(time.Duration(cfg.Balancer.HealthCheckInterval), cfg.Health)
 
 	// Initialize backends
 	for _, bc := range cfg.Backends {
+		tlsConfig, err := newBackendTLSConfig(bc)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", bc.Addr(), err)
+		}
+
 		backend := &backend{
-			host:   bc.Host,
-			port:   bc.Port,
-			weight: bc.Weight,
-			health: true,
+			host:      bc.Host,
+			port:      bc.Port,
+			tls:       tlsConfig,
+			backup:    bc.Backup,
+			conns:     make(map[net.Conn]struct{}),
+			slowStart: time.Duration(cfg.SlowStart.Duration),
+		}
+		backend.weight.Store(int64(bc.Weight))
+		backend.health.Store(true)
+		if _, loaded := b.backends.LoadOrStore(backend.addr(), backend); loaded {
+			return nil, fmt.Errorf("duplicate backend: %s", backend.addr())
 		}
-		b.backends.Store(fmt.Sprintf("%s:%d", bc.Host, bc.Port), backend)
-		b.hasher.Add(backend.host, backend.weight)
+		b.hasher.Add(backend.addr(), bc.Weight)
+		b.health.Add(backend.addr())
 	}
 
 	return b, nil
@@ -82,6 +176,10 @@ func (b *balancer) Start(ctx context.Context) error {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	http.HandleFunc("/health/detail", b.handleHealthDetail)
+	http.HandleFunc("/ready", b.handleReady)
+	http.HandleFunc("/metrics", b.handleMetrics)
+	http.HandleFunc("/pool", b.handlePool)
 
 	// Start health check server with context cancellation
 	go func() {
@@ -99,7 +197,7 @@ func (b *balancer) Start(ctx context.Context) error {
 	}()
 
 	// Start main load balancer
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", b.cfg.Balancer.Port))
+	listener, err := netutil.Listen(b.cfg.Balancer.Listen)
 	if err != nil {
 		return fmt.Errorf("starting listener: %w", err)
 	}
@@ -108,22 +206,49 @@ func (b *balancer) Start(ctx context.Context) error {
 	// Start health checker
 	go b.health.Start(ctx, b.updateBackendHealth)
 
-	log.Printf("Load balancer listening on :%d", b.cfg.Balancer.Port)
+	// Start slow start weight ramping
+	go b.rampWeights(ctx)
+
+	log.Printf("Load balancer listening on %s (%s mode)", b.cfg.Balancer.Listen, b.cfg.Balancer.Mode)
+
+	if b.cfg.Balancer.Mode == config.ModeHTTP {
+		return b.serveHTTP(ctx, listener)
+	}
 
+	wait := b.cfg.Balancer.OnOverflow == config.OverflowWait
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
+			// In wait mode, stop accepting until a slot frees up so excess
+			// clients queue in the listen backlog
+			if wait && !b.waitSlot(ctx) {
+				return nil
+			}
+
 			conn, err := listener.Accept()
 			if err != nil {
+				if wait {
+					b.releaseSlot()
+				}
 				if ctx.Err() != nil {
 					return nil
 				}
 				log.Printf("Error accepting connection: %v", err)
 				continue
 			}
-			go b.handleConnection(ctx, conn)
+
+			if !wait && !b.trySlot() {
+				log.Printf("Rejecting connection from %s: max concurrent connections reached", conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+
+			go func() {
+				defer b.releaseSlot()
+				b.handleConnection(ctx, conn)
+			}()
 		}
 	}
 }
@@ -147,61 +272,253 @@ func (b *balancer) Shutdown(ctx context.Context) error {
 func (b *balancer) handleConnection(ctx context.Context, clientConn net.Conn) {
 	defer clientConn.Close()
 
-	// Get backend using consistent hashing
-	backend, err := b.getHealthyBackend(clientConn.RemoteAddr().String())
-	if err != nil {
-		log.Printf("Error getting backend: %v", err)
-		return
+	if b.cfg.KeepAlive.Enabled {
+		if err := netutil.SetKeepAlive(clientConn, time.Duration(b.cfg.KeepAlive.Period)); err != nil {
+			log.Printf("Error setting client keep-alive: %v", err)
+		}
 	}
 
+	tracer := tracing.Tracer()
+	ctx, span := tracer.Start(ctx, "handle_connection")
+	defer span.End()
+	span.SetAttributes(attribute.String("client.address", clientConn.RemoteAddr().String()))
+
+	attempts := 1
+	bufferBytes := 0
+	if b.cfg.Retry.Enabled {
+		attempts = b.cfg.Retry.MaxAttempts
+		bufferBytes = b.cfg.Retry.BufferBytes
+	}
+	client := newReplayReader(clientConn, bufferBytes)
+	tried := make(map[string]bool)
+
+	for attempt := 1; ; attempt++ {
+		// Get backend using the configured strategy
+		selectCtx, selectSpan := tracer.Start(ctx, "select_backend")
+		backend, err := b.getHealthyBackend(selectCtx, clientConn.RemoteAddr().String(), tried)
+		selectSpan.End()
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Printf("Error getting backend: %v", err)
+			if attempt == 1 {
+				b.rejectNoBackend(clientConn)
+			}
+			return
+		}
+		tried[backend.addr()] = true
+		span.SetAttributes(attribute.String("backend.address", backend.addr()))
+
+		retry := b.proxyAttempt(ctx, clientConn, client, backend)
+		if !retry || attempt >= attempts {
+			return
+		}
+		log.Printf("Retrying connection from %s after backend %s failed", clientConn.RemoteAddr(), backend.addr())
+	}
+}
+
+// proxyAttempt proxies the client connection to one backend. It returns true
+// if the backend failed before responding and the client's bytes so far can
+// be safely replayed to another backend.
+func (b *balancer) proxyAttempt(ctx context.Context, clientConn net.Conn, client *replayReader, backend *backend) bool {
+	tracer := tracing.Tracer()
+	span := trace.SpanFromContext(ctx)
+
+	backend.active.Add(1)
+	defer backend.active.Add(-1)
+
 	// Get backend connection from pool
-	backendConn, err := b.pool.Get(fmt.Sprintf("%s:%d", backend.host, backend.port))
+	_, dialSpan := tracer.Start(ctx, "dial_backend")
+	backendConn, err := b.pool.Get(backend.addr())
+	dialSpan.End()
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		log.Printf("Error getting backend connection: %v", err)
-		return
+		return client.replayable()
+	}
+	backend.track(backendConn)
+	failed := false
+	defer func(conn net.Conn) {
+		backend.untrack(conn)
+		if failed {
+			b.pool.Discard(conn)
+		} else {
+			b.releaseConn(backend, conn)
+		}
+	}(backendConn)
+
+	// Time the backend's first response for the latency strategies
+	if b.sampleLatency {
+		backendConn = &latencyConn{Conn: backendConn, backend: backend}
+	}
+
+	// Replay client bytes sent to a previous backend that failed
+	if err := client.replay(backendConn); err != nil {
+		log.Printf("Error replaying client data to %s: %v", backend.addr(), err)
+		failed = true
+		return client.replayable()
 	}
-	defer b.pool.Put(backendConn)
 
 	// Forward traffic between client and backend
-	errCh := make(chan error, 2)
-	go b.proxy(clientConn, backendConn, errCh)
-	go b.proxy(backendConn, clientConn, errCh)
+	_, proxySpan := tracer.Start(ctx, "proxy")
+	defer proxySpan.End()
+	var sent, received atomic.Int64
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("bytes.sent", sent.Load()),
+			attribute.Int64("bytes.received", received.Load()),
+		)
+	}()
+
+	upCh := make(chan error, 1)
+	go b.proxy(backendConn, client.source(), &sent, upCh)
+
+	if client.replayable() {
+		// Hold the response until the backend sends its first byte, so a
+		// backend that fails before responding can be retried
+		if err := b.awaitFirstByte(clientConn, backendConn, client, &received); err != nil {
+			failed = true
+			// Stop the client reader so the next attempt can take over
+			clientConn.SetReadDeadline(time.Now())
+			<-upCh
+			clientConn.SetReadDeadline(time.Time{})
+			return client.replayable()
+		}
+	}
+
+	downCh := make(chan error, 1)
+	go b.proxy(clientConn, backendConn, &received, downCh)
 
 	// Wait for either connection to close
-	<-errCh
+	select {
+	case <-upCh:
+	case <-downCh:
+	}
+	return false
+}
+
+// awaitFirstByte waits for the backend's first response bytes and forwards
+// them to the client, after which the connection can no longer be retried
+func (b *balancer) awaitFirstByte(clientConn, backendConn net.Conn, client *replayReader, received *atomic.Int64) error {
+	buf := make([]byte, 4096)
+	n, err := backendConn.Read(buf)
+	if n == 0 {
+		if err == nil {
+			err = io.ErrNoProgress
+		}
+		return err
+	}
+
+	// The backend has responded, so a client write error is not a reason
+	// to retry; the proxy loop will notice the broken client connection
+	client.commit()
+	if n, err = clientConn.Write(buf[:n]); err == nil {
+		received.Add(int64(n))
+	}
+	return nil
 }
 
-// proxy copies data between two connections
-func (b *balancer) proxy(dst, src net.Conn, errCh chan<- error) {
-	_, err := io.Copy(dst, src)
+// proxy copies data between two connections, counting the bytes written
+func (b *balancer) proxy(dst io.Writer, src io.Reader, written *atomic.Int64, errCh chan<- error) {
+	n, err := b.copy(dst, src)
+	written.Add(n)
 	errCh <- err
 }
 
-// getHealthyBackend returns a healthy backend server
-func (b *balancer) getHealthyBackend(key string) (*backend, error) {
-	host := b.hasher.Get(key)
-	if host == "" {
-		return nil, fmt.Errorf("no backend available")
+// newBufferPool returns a pool of copy buffers of the given size
+func newBufferPool(size int) *sync.Pool {
+	return &sync.Pool{
+		New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		},
 	}
+}
 
-	value, ok := b.backends.Load(host)
-	if !ok {
-		return nil, fmt.Errorf("backend not found: %s", host)
+// copy copies src to dst through a pooled buffer of the configured size
+func (b *balancer) copy(dst io.Writer, src io.Reader) (int64, error) {
+	if b.buffers == nil {
+		return io.Copy(dst, src)
 	}
 
-	backend := value.(*backend)
-	if !backend.health {
-		return nil, fmt.Errorf("backend unhealthy: %s", host)
+	buf := b.buffers.Get().(*[]byte)
+	defer b.buffers.Put(buf)
+
+	// Hide ReaderFrom/WriterTo so io.CopyBuffer actually uses our buffer
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
+
+// getHealthyBackend returns a healthy backend server, preferring primaries
+// and falling back to the backup tier when no primary is eligible. Backends
+// whose address is in exclude are skipped.
+func (b *balancer) getHealthyBackend(ctx context.Context, clientAddr string, exclude map[string]bool) (*backend, error) {
+	candidates := b.healthyBackends(false, exclude)
+	if len(candidates) == 0 {
+		candidates = b.healthyBackends(true, exclude)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no backend available")
+	}
+
+	backend, err := b.strategy.next(ctx, clientAddr, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("selecting backend for %s: %w", clientAddr, err)
 	}
 
 	return backend, nil
 }
 
-// updateBackendHealth updates the health status of a backend
+// healthyBackends returns the healthy backends of the given tier eligible
+// for new connections, sorted by address. Zero-weight backends are draining
+// and are skipped.
+func (b *balancer) healthyBackends(backup bool, exclude map[string]bool) []*backend {
+	var candidates []*backend
+	b.backends.Range(func(addr, value any) bool {
+		backend := value.(*backend)
+		if exclude[addr.(string)] {
+			return true
+		}
+		if backend.backup == backup && backend.health.Load() && backend.weight.Load() > 0 {
+			candidates = append(candidates, backend)
+		}
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].addr() < candidates[j].addr()
+	})
+	return candidates
+}
+
+// updateBackendWeight changes a backend's weight. A weight of zero stops new
+// connections to the backend while existing ones finish and health checks
+// continue; raising it again makes the backend eligible.
+func (b *balancer) updateBackendWeight(addr string, weight int) {
+	if value, ok := b.backends.Load(addr); ok {
+		backend := value.(*backend)
+		backend.weight.Store(int64(weight))
+		b.setRingWeight(backend)
+		b.backendsChanged()
+	}
+}
+
+// backendsChanged notifies the strategy that backend health or weights
+// changed
+func (b *balancer) backendsChanged() {
+	if inv, ok := b.strategy.(invalidator); ok {
+		inv.invalidate()
+	}
+}
+
+// updateBackendHealth updates the health status of a backend from the
+// checker's phi-accrual verdict
 func (b *balancer) updateBackendHealth(host string, healthy bool) {
 	if value, ok := b.backends.Load(host); ok {
 		backend := value.(*backend)
-		backend.health = healthy
-		b.backends.Store(host, backend)
+		if backend.health.Swap(healthy) != healthy {
+			log.Printf("Backend %s health changed: healthy=%v", host, healthy)
+			if healthy {
+				b.startSlowStart(backend)
+			}
+			b.backendsChanged()
+		}
 	}
 }
@@ -0,0 +1,103 @@
+package balancer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestPooledCopy(t *testing.T) {
+	data := make([]byte, 1<<20+17)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, size := range []int{0, 4 << 10, 256 << 10} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			b := &balancer{}
+			if size > 0 {
+				b.buffers = newBufferPool(size)
+			}
+			for range 3 { // buffers come back from the pool intact
+				var dst bytes.Buffer
+				n, err := b.copy(&dst, bytes.NewReader(data))
+				if err != nil || n != int64(len(data)) {
+					t.Fatalf("copied %d bytes, %v; want %d", n, err, len(data))
+				}
+				if !bytes.Equal(dst.Bytes(), data) {
+					t.Fatal("copied bytes differ from the source")
+				}
+			}
+		})
+	}
+}
+
+func TestProxyBufferSizeFromConfig(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("proxy: {buffer_size: 65536}\n", echo)))
+	if b.buffers == nil {
+		t.Fatal("proxy.buffer_size set but no buffer pool")
+	}
+	if buf := b.buffers.Get().(*[]byte); len(*buf) != 64<<10 {
+		t.Errorf("pooled buffer is %d bytes, want 65536", len(*buf))
+	}
+
+	if b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo))); b.buffers != nil {
+		t.Error("buffer pool without proxy.buffer_size")
+	}
+}
+
+// BenchmarkProxyCopy copies a bulk transfer between loopback TCP
+// connections through pooled buffers of each size
+func BenchmarkProxyCopy(b *testing.B) {
+	const transfer = 16 << 20
+	for _, size := range []int{32 << 10, 256 << 10} {
+		b.Run(strconv.Itoa(size>>10)+"KB", func(b *testing.B) {
+			lb := &balancer{buffers: newBufferPool(size)}
+			src, dst := tcpPair(b), tcpPair(b)
+			go func() {
+				chunk := make([]byte, 64<<10)
+				for {
+					if _, err := src[1].Write(chunk); err != nil {
+						return
+					}
+				}
+			}()
+			go io.Copy(io.Discard, dst[1])
+
+			b.SetBytes(transfer)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := lb.copy(dst[0], io.LimitReader(src[0], transfer)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// tcpPair returns both ends of a loopback TCP connection, closed when the
+// benchmark ends
+func tcpPair(b *testing.B) [2]net.Conn {
+	b.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return [2]net.Conn{client, server}
+}
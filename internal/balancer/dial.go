@@ -0,0 +1,85 @@
+package balancer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+	"github.com/ritikchawla/load-balancer/internal/netutil"
+)
+
+// dialTimeout bounds connecting to a backend, including any TLS handshake
+const dialTimeout = 5 * time.Second
+
+// dialBackend opens a connection to a backend address, applying keep-alive
+// and the backend's TLS settings
+func (b *balancer) dialBackend(addr string) (net.Conn, error) {
+	conn, err := netutil.DialTimeout(addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.cfg.KeepAlive.Enabled {
+		if err := netutil.SetKeepAlive(conn, time.Duration(b.cfg.KeepAlive.Period)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("setting keep-alive: %w", err)
+		}
+	}
+
+	value, ok := b.backends.Load(addr)
+	if !ok || value.(*backend).tls == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, value.(*backend).tls)
+	tlsConn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake: %w", err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// newBackendTLSConfig builds the client TLS configuration for a backend, or
+// returns nil if TLS is disabled
+func newBackendTLSConfig(bc config.BackendConfig) (*tls.Config, error) {
+	if !bc.TLS.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: bc.TLS.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" && !netutil.IsUnix(bc.Host) {
+		tlsConfig.ServerName = bc.Host
+	}
+
+	if bc.TLS.CAFile != "" {
+		pem, err := os.ReadFile(bc.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", bc.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if bc.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(bc.TLS.CertFile, bc.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
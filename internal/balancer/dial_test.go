@@ -0,0 +1,81 @@
+package balancer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sockoptConn records the socket options set on it
+type sockoptConn struct {
+	net.Conn
+
+	mu        sync.Mutex
+	keepAlive bool
+	period    time.Duration
+}
+
+func (c *sockoptConn) SetKeepAlive(on bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keepAlive = on
+	return nil
+}
+
+func (c *sockoptConn) SetKeepAlivePeriod(d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.period = d
+	return nil
+}
+
+// serveOne proxies one message over a client connection recording its
+// socket options and returns that connection once the proxy is done
+func serveOne(t *testing.T, b *balancer) *sockoptConn {
+	t.Helper()
+	client, server := net.Pipe()
+	rc := &sockoptConn{Conn: server}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.handleConnection(context.Background(), rc)
+	}()
+
+	client.SetDeadline(time.Now().Add(time.Second))
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := client.Read(buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echo = %q, %v", buf, err)
+	}
+	client.Close()
+	<-done
+	return rc
+}
+
+func TestClientKeepAlive(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("keepalive: {enabled: true, period: 42s}\n", echo)))
+
+	rc := serveOne(t, b)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.keepAlive || rc.period != 42*time.Second {
+		t.Errorf("keep-alive = %t, period %v; want enabled with 42s", rc.keepAlive, rc.period)
+	}
+}
+
+func TestClientKeepAliveDisabled(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+
+	rc := serveOne(t, b)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.keepAlive || rc.period != 0 {
+		t.Errorf("keep-alive set although disabled: %t, %v", rc.keepAlive, rc.period)
+	}
+}
@@ -0,0 +1,86 @@
+package balancer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// drainPollInterval is how often removal checks for in-flight connections
+const drainPollInterval = 10 * time.Millisecond
+
+// track registers a live backend connection
+func (be *backend) track(conn net.Conn) {
+	be.connsMu.Lock()
+	be.conns[conn] = struct{}{}
+	be.connsMu.Unlock()
+}
+
+// untrack unregisters a backend connection once proxying is done
+func (be *backend) untrack(conn net.Conn) {
+	be.connsMu.Lock()
+	delete(be.conns, conn)
+	be.connsMu.Unlock()
+}
+
+// closeConns force-closes all live backend connections
+func (be *backend) closeConns() {
+	be.connsMu.Lock()
+	defer be.connsMu.Unlock()
+
+	for conn := range be.conns {
+		conn.Close()
+	}
+}
+
+// releaseConn hands a backend connection back to the pool, or discards it if
+// the backend has been removed
+func (b *balancer) releaseConn(be *backend, conn net.Conn) {
+	if be.removed.Load() {
+		b.pool.Discard(conn)
+		return
+	}
+	b.pool.Put(conn)
+}
+
+// RemoveBackend removes a backend immediately, severing its connections
+func (b *balancer) RemoveBackend(host string, port int) error {
+	_, err := b.RemoveBackendGracefully(host, port, 0)
+	return err
+}
+
+// RemoveBackendGracefully removes a backend from selection right away and
+// waits up to timeout for its in-flight connections to finish. Connections
+// still open at the deadline are force-closed and counted as dropped.
+func (b *balancer) RemoveBackendGracefully(host string, port int, timeout time.Duration) (int, error) {
+	addr := fmt.Sprintf("%s:%d", host, port)
+	value, ok := b.backends.LoadAndDelete(addr)
+	if !ok {
+		return 0, fmt.Errorf("backend not found: %s", addr)
+	}
+	backend := value.(*backend)
+	backend.removed.Store(true)
+
+	// Stop new selections and probes
+	b.hasher.Remove(addr)
+	b.health.Remove(addr)
+	b.backendsChanged()
+
+	// Let in-flight connections finish until the deadline
+	deadline := time.Now().Add(timeout)
+	for backend.active.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+
+	dropped := int(backend.active.Load())
+	if dropped > 0 {
+		backend.closeConns()
+		log.Printf("Removed backend %s: dropped %d in-flight connections", addr, dropped)
+	} else {
+		log.Printf("Removed backend %s after draining", addr)
+	}
+
+	b.pool.Evict(addr)
+	return dropped, nil
+}
@@ -0,0 +1,94 @@
+package balancer
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// splitAddr splits a test server address into host and port
+func splitAddr(t *testing.T, addr string) (string, int) {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+func TestRemoveBackendGracefullyLetsTransferFinish(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+	conn := pipeClient(t, b)
+	sendAndExpect(t, conn, "before")
+
+	type result struct {
+		dropped int
+		err     error
+	}
+	done := make(chan result, 1)
+	host, port := splitAddr(t, echo)
+	go func() {
+		dropped, err := b.RemoveBackendGracefully(host, port, 5*time.Second)
+		done <- result{dropped, err}
+	}()
+
+	// New selections stop right away
+	eventually(t, "the backend leaves selection", func() bool {
+		_, err := b.getHealthyBackend(context.Background(), "127.0.0.1:5000", nil)
+		return err != nil
+	})
+
+	// The connection in flight keeps working while the removal waits
+	for _, msg := range []string{"during", "still going"} {
+		sendAndExpect(t, conn, msg)
+	}
+	select {
+	case r := <-done:
+		t.Fatalf("removal returned (%d, %v) with a connection in flight", r.dropped, r.err)
+	default:
+	}
+
+	conn.Close()
+	select {
+	case r := <-done:
+		if r.err != nil || r.dropped != 0 {
+			t.Errorf("removal returned (%d, %v), want nothing dropped", r.dropped, r.err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("removal did not finish once the connection closed")
+	}
+}
+
+func TestRemoveBackendGracefullyForceClosesAtDeadline(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+	conn := pipeClient(t, b)
+	sendAndExpect(t, conn, "hello")
+
+	host, port := splitAddr(t, echo)
+	start := time.Now()
+	dropped, err := b.RemoveBackendGracefully(host, port, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 1 {
+		t.Errorf("dropped %d connections, want 1", dropped)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("removal returned after %v, before the deadline", waited)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Error("client connection still open after the deadline")
+	}
+
+	if _, err := b.RemoveBackendGracefully(host, port, time.Second); err == nil {
+		t.Error("removing again succeeded, want a backend not found error")
+	}
+}
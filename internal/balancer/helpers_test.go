@@ -0,0 +1,179 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// newTestBackend returns a healthy backend at addr with the given weight,
+// not registered with any balancer
+func newTestBackend(addr string, weight int) *backend {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		panic(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	be := &backend{
+		host: host,
+		port: port,
+	}
+	be.weight.Store(int64(weight))
+	be.health.Store(true)
+	return be
+}
+
+// testYAML returns a configuration listening on localhost with admin and
+// health checks off and the given backends, each of weight 1, followed by
+// extra top-level sections
+func testYAML(extra string, backends ...string) string {
+	var b strings.Builder
+	b.WriteString("balancer: {port: 9000, health_check_interval: 1s}\n")
+	b.WriteString("admin: {enabled: false}\n")
+	b.WriteString("health: {enabled: false}\n")
+	b.WriteString("pool: {max_idle: 4, max_active: 16, idle_timeout: 30s}\n")
+	b.WriteString("backends:\n")
+	for _, addr := range backends {
+		host, port, _ := net.SplitHostPort(addr)
+		fmt.Fprintf(&b, "  - {host: %s, port: %s, weight: 1}\n", host, port)
+	}
+	b.WriteString(extra)
+	return b.String()
+}
+
+// loadTestConfig loads a YAML configuration. The balancer is moved to an
+// ephemeral port, which validation would reject in the file.
+func loadTestConfig(t *testing.T, doc string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lb.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("loading config: %v\n%s", err, doc)
+	}
+	cfg.Balancer.Port = 0
+	return cfg
+}
+
+// newTestBalancer creates a balancer from cfg without starting it
+func newTestBalancer(t *testing.T, cfg *config.Config) *balancer {
+	t.Helper()
+	lb, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lb.(*balancer)
+}
+
+// startEcho starts a TCP server echoing every connection, closed when the
+// test ends, and returns its address
+func startEcho(t *testing.T) string {
+	t.Helper()
+	return startServer(t, func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+}
+
+// startServer starts a TCP server running handle on every connection, which
+// is closed after handle returns, and returns its address. The server is
+// closed when the test ends.
+func startServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// roundTrip sends msg over a new connection to addr and returns what comes
+// back before the server closes the connection or a second passes
+func roundTrip(t *testing.T, addr, msg string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	n, _ := io.ReadFull(conn, buf)
+	return string(buf[:n])
+}
+
+// sendAndExpect writes msg on an echoed connection and checks it comes back
+func sendAndExpect(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("writing %q: %v", msg, err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("reading back %q: %v", msg, err)
+	}
+	if string(buf) != msg {
+		t.Fatalf("echoed %q, want %q", buf, msg)
+	}
+}
+
+// startHTTPBackend serves handler over HTTP on localhost until the test
+// ends and returns the address
+func startHTTPBackend(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// pipeClient hands one end of an in-memory connection to the balancer as
+// a client connection and returns the other end, closed when the test ends
+func pipeClient(t *testing.T, b *balancer) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	go b.handleConnection(context.Background(), server)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// eventually polls cond until it holds or a few seconds pass
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
@@ -0,0 +1,176 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ritikchawla/load-balancer/internal/netutil"
+	"github.com/ritikchawla/load-balancer/internal/tracing"
+)
+
+// backendContextKey carries the selected backend from ServeHTTP to the
+// reverse proxy's Rewrite hook
+type backendContextKey struct{}
+
+// serveHTTP runs the L7 reverse proxy on the listener until ctx is canceled
+func (b *balancer) serveHTTP(ctx context.Context, listener net.Listener) error {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			backend := pr.In.Context().Value(backendContextKey{}).(*backend)
+			pr.SetURL(&url.URL{Scheme: "http", Host: backend.httpHost()})
+			pr.SetXForwarded()
+			pr.Out.Host = pr.In.Host
+			tracing.InjectHTTP(pr.Out.Context(), pr.Out.Header)
+		},
+		// Dial through the balancer so backend keep-alive and TLS settings
+		// apply to proxied requests too
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				// Unix socket backends can't be named in a URL, so dial the
+				// backend the request was routed to
+				if backend, ok := ctx.Value(backendContextKey{}).(*backend); ok {
+					return b.dialBackend(backend.addr())
+				}
+				return b.dialBackend(addr)
+			},
+			MaxIdleConnsPerHost: b.cfg.Pool.MaxIdle,
+			IdleConnTimeout:     time.Duration(b.cfg.Pool.IdleTimeout),
+		},
+	}
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b.handleHTTP(w, r, proxy)
+		}),
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("HTTP proxy shutdown error: %v", err)
+		}
+	}()
+
+	if err := srv.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// handleHTTP proxies a single HTTP request to a selected backend
+func (b *balancer) handleHTTP(w http.ResponseWriter, r *http.Request, proxy *httputil.ReverseProxy) {
+	ctx := tracing.ExtractHTTP(r.Context(), r.Header)
+	ctx, span := tracing.Tracer().Start(ctx, "handle_request")
+	defer span.End()
+	span.SetAttributes(attribute.String("client.address", r.RemoteAddr))
+
+	selectCtx := context.WithValue(ctx, requestContextKey{}, r)
+	backend, err := b.getHealthyBackend(selectCtx, r.RemoteAddr, nil)
+	if err != nil {
+		log.Printf("Error getting backend: %v", err)
+		http.Error(w, "no backend available", http.StatusServiceUnavailable)
+		return
+	}
+	backend.active.Add(1)
+	defer backend.active.Add(-1)
+	span.SetAttributes(attribute.String("backend.address", backend.addr()))
+
+	if isUpgrade(r) {
+		b.proxyUpgrade(ctx, w, r, backend)
+		return
+	}
+
+	ctx = context.WithValue(ctx, backendContextKey{}, backend)
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// httpHost returns the URL host requests to the backend are sent to. It
+// also keys the transport's idle connections, so it must be unique per
+// backend; Unix socket paths are hex encoded into a placeholder name.
+func (be *backend) httpHost() string {
+	if netutil.IsUnix(be.host) {
+		return fmt.Sprintf("%x.unix", strings.TrimPrefix(be.host, netutil.UnixPrefix))
+	}
+	return be.addr()
+}
+
+// isUpgrade reports whether the request asks to switch protocols, e.g. to
+// WebSocket
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyUpgrade forwards an upgrade request to the backend unchanged, then
+// hijacks the client connection and copies raw bytes in both directions so
+// the backend's 101 response and subsequent frames pass through untouched
+func (b *balancer) proxyUpgrade(ctx context.Context, w http.ResponseWriter, r *http.Request, backend *backend) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "upgrade not supported", http.StatusInternalServerError)
+		return
+	}
+
+	backendConn, err := b.pool.Get(backend.addr())
+	if err != nil {
+		log.Printf("Error getting backend connection: %v", err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	// An upgraded connection can't be handed to another client
+	backend.track(backendConn)
+	defer func() {
+		backend.untrack(backendConn)
+		b.pool.Discard(backendConn)
+	}()
+
+	tracing.InjectHTTP(ctx, r.Header)
+	if err := r.Write(backendConn); err != nil {
+		log.Printf("Error forwarding upgrade request: %v", err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+
+	clientConn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error hijacking connection: %v", err)
+		return
+	}
+	defer clientConn.Close()
+
+	// Forward any client bytes the server read ahead before the hijack
+	if n := buf.Reader.Buffered(); n > 0 {
+		pending, _ := buf.Reader.Peek(n)
+		if _, err := backendConn.Write(pending); err != nil {
+			log.Printf("Error forwarding buffered bytes: %v", err)
+			return
+		}
+	}
+
+	var sent, received atomic.Int64
+	errCh := make(chan error, 2)
+	go b.proxy(clientConn, backendConn, &received, errCh)
+	go b.proxy(backendConn, clientConn, &sent, errCh)
+
+	// Wait for either connection to close
+	<-errCh
+}
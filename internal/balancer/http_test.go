@@ -0,0 +1,146 @@
+package balancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// startHTTPBalancer runs the HTTP proxy of a balancer for the backends on
+// localhost until the test ends and returns its address
+func startHTTPBalancer(t *testing.T, backends ...string) string {
+	t.Helper()
+	cfg := loadTestConfig(t, testYAML("", backends...))
+	cfg.Balancer.Mode = config.ModeHTTP
+	return serveHTTPBalancer(t, newTestBalancer(t, cfg))
+}
+
+// serveHTTPBalancer runs the HTTP proxy of b until the test ends and
+// returns its address
+func serveHTTPBalancer(t *testing.T, b *balancer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.serveHTTP(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+// upgradeHandler answers a WebSocket-style upgrade with 101, greets the
+// client and then echoes every line back prefixed with "echo: "
+func upgradeHandler(t *testing.T, headers chan<- http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		if !isUpgrade(r) {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijacking: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		fmt.Fprintf(rw, "hello\n")
+		rw.Flush()
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(rw, "echo: %s", line)
+			rw.Flush()
+		}
+	})
+}
+
+func TestWebSocketUpgradePassthrough(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	backend := startHTTPBackend(t, upgradeHandler(t, headers))
+	addr := startHTTPBalancer(t, backend)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", addr)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status %s, want 101", resp.Status)
+	}
+	if got := resp.Header.Get("Upgrade"); got != "websocket" {
+		t.Errorf("client got Upgrade %q, want websocket", got)
+	}
+
+	got := <-headers
+	if got.Get("Upgrade") != "websocket" || !strings.EqualFold(got.Get("Connection"), "upgrade") {
+		t.Errorf("backend got Upgrade %q, Connection %q; want the upgrade headers preserved",
+			got.Get("Upgrade"), got.Get("Connection"))
+	}
+	if got.Get("Sec-WebSocket-Key") == "" {
+		t.Error("backend did not get Sec-WebSocket-Key")
+	}
+
+	// Frames flow from the backend unprompted and in both directions
+	readLine := func(want string) {
+		t.Helper()
+		line, err := r.ReadString('\n')
+		if err != nil || line != want+"\n" {
+			t.Fatalf("read %q, %v; want %q", line, err, want)
+		}
+	}
+	readLine("hello")
+	for _, msg := range []string{"ping", "second frame"} {
+		fmt.Fprintf(conn, "%s\n", msg)
+		readLine("echo: " + msg)
+	}
+
+	// Closing the client ends the tunnel and the backend sees EOF
+	conn.(*net.TCPConn).CloseWrite()
+	if rest, err := io.ReadAll(r); err != nil || len(rest) != 0 {
+		t.Errorf("after half-close read %q, %v; want a clean EOF", rest, err)
+	}
+}
+
+func TestHTTPModeNonUpgradeUnaffected(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	backend := startHTTPBackend(t, upgradeHandler(t, headers))
+	addr := startHTTPBalancer(t, backend)
+
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("plain request got %s, want the backend's 426", resp.Status)
+	}
+	<-headers
+}
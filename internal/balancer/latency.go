@@ -0,0 +1,137 @@
+package balancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencyAlpha is the EWMA smoothing factor for new latency samples
+	latencyAlpha = 0.3
+	// latencyExploreRate is the share of selections that pick a random
+	// backend so slower backends keep being sampled and can recover
+	latencyExploreRate = 0.05
+)
+
+// observeLatency folds a response latency sample into the backend's
+// exponentially weighted moving average
+func (be *backend) observeLatency(sample time.Duration) {
+	for {
+		old := be.latency.Load()
+		next := int64(sample)
+		if old != 0 {
+			next = int64(latencyAlpha*float64(sample) + (1-latencyAlpha)*float64(old))
+		}
+		if next == 0 {
+			next = 1 // zero means no samples
+		}
+		if be.latency.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// leastResponseTimeStrategy picks the backend with the lowest latency EWMA,
+// optionally divided by its effective weight
+type leastResponseTimeStrategy struct {
+	weighted bool
+
+	mu  sync.Mutex // guards rng
+	rng *rand.Rand
+}
+
+func newLeastResponseTimeStrategy(weighted bool) *leastResponseTimeStrategy {
+	seed := uint64(time.Now().UnixNano())
+	return &leastResponseTimeStrategy{
+		weighted: weighted,
+		rng:      rand.New(rand.NewPCG(seed, seed>>32)),
+	}
+}
+
+func (s *leastResponseTimeStrategy) next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error) {
+	if len(candidates) == 0 {
+		return nil, errNoCandidate
+	}
+
+	s.mu.Lock()
+	pick := -1
+	if s.rng.Float64() < latencyExploreRate {
+		pick = s.rng.IntN(len(candidates))
+	}
+	s.mu.Unlock()
+	if pick >= 0 {
+		return candidates[pick], nil
+	}
+
+	// Backends without samples get the average of the others, so they are
+	// neither starved nor flooded
+	var sum float64
+	var sampled int
+	for _, be := range candidates {
+		if l := be.latency.Load(); l > 0 {
+			sum += float64(l)
+			sampled++
+		}
+	}
+	neutral := 0.0
+	if sampled > 0 {
+		neutral = sum / float64(sampled)
+	}
+
+	var best *backend
+	var bestScore float64
+	for _, be := range candidates {
+		score := float64(be.latency.Load())
+		if score == 0 {
+			score = neutral
+		}
+		if s.weighted {
+			weight := be.effectiveWeight()
+			if weight <= 0 {
+				continue
+			}
+			score /= float64(weight)
+		}
+		if best == nil || score < bestScore {
+			best = be
+			bestScore = score
+		}
+	}
+	if best == nil {
+		return nil, errNoCandidate
+	}
+	return best, nil
+}
+
+// latencyConn samples a backend's response latency: the time from the first
+// write to it to the first byte it sends back. Waiting for and reusing a
+// pooled connection are left out, and a backend that speaks before anything
+// is written to it gives no sample.
+type latencyConn struct {
+	net.Conn
+	backend *backend
+	wrote   atomic.Int64 // unix nanoseconds of the first write, zero before
+	sampled atomic.Bool
+}
+
+func (c *latencyConn) Write(p []byte) (int, error) {
+	if len(p) > 0 && c.wrote.Load() == 0 {
+		c.wrote.CompareAndSwap(0, time.Now().UnixNano())
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *latencyConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && !c.sampled.Load() {
+		c.sampled.Store(true)
+		if wrote := c.wrote.Load(); wrote != 0 {
+			c.backend.observeLatency(time.Since(time.Unix(0, wrote)))
+		}
+	}
+	return n, err
+}
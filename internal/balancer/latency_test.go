@@ -0,0 +1,204 @@
+package balancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+func TestObserveLatency(t *testing.T) {
+	be := newTestBackend("10.0.0.1:80", 1)
+	be.observeLatency(100 * time.Millisecond)
+	if got := time.Duration(be.latency.Load()); got != 100*time.Millisecond {
+		t.Fatalf("first sample gave %v, want it taken as is", got)
+	}
+	be.observeLatency(200 * time.Millisecond)
+	if got := time.Duration(be.latency.Load()); got != 130*time.Millisecond {
+		t.Errorf("EWMA after 100ms then 200ms = %v, want 130ms", got)
+	}
+
+	// A zero sample still marks the backend as sampled
+	fresh := newTestBackend("10.0.0.2:80", 1)
+	fresh.observeLatency(0)
+	if fresh.latency.Load() == 0 {
+		t.Error("zero sample left the backend looking unsampled")
+	}
+}
+
+// latencyPicks returns how often each backend is picked over n selections
+func latencyPicks(t *testing.T, s strategy, candidates []*backend, n int) map[*backend]int {
+	t.Helper()
+	picks := make(map[*backend]int)
+	for range n {
+		be, err := s.next(context.Background(), "", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picks[be]++
+	}
+	return picks
+}
+
+func TestLeastResponseTimeConcentratesOnFastest(t *testing.T) {
+	fast, medium, slow := newTestBackend("10.0.0.1:80", 1), newTestBackend("10.0.0.2:80", 1), newTestBackend("10.0.0.3:80", 1)
+	fast.observeLatency(5 * time.Millisecond)
+	medium.observeLatency(50 * time.Millisecond)
+	slow.observeLatency(200 * time.Millisecond)
+	candidates := []*backend{slow, medium, fast}
+	s := newLeastResponseTimeStrategy(false)
+
+	const n = 4000
+	picks := latencyPicks(t, s, candidates, n)
+	if share := float64(picks[fast]) / n; share < 0.9 {
+		t.Errorf("fastest backend got %.3f of picks, want over 0.9", share)
+	}
+	for _, be := range []*backend{medium, slow} {
+		if picks[be] == 0 {
+			t.Errorf("%s never picked; slower backends must still be sampled", be.addr())
+		}
+	}
+
+	// Once the fastest slows down, traffic moves away from it
+	for range 20 {
+		fast.observeLatency(500 * time.Millisecond)
+	}
+	picks = latencyPicks(t, s, candidates, n)
+	if share := float64(picks[medium]) / n; share < 0.9 {
+		t.Errorf("after the fastest slowed down the next fastest got %.3f of picks", share)
+	}
+}
+
+func TestLeastResponseTimeNeutralEstimate(t *testing.T) {
+	fast, slow, fresh := newTestBackend("10.0.0.1:80", 1), newTestBackend("10.0.0.2:80", 1), newTestBackend("10.0.0.3:80", 1)
+	fast.observeLatency(10 * time.Millisecond)
+	slow.observeLatency(50 * time.Millisecond)
+	s := newLeastResponseTimeStrategy(false)
+
+	// The unsampled backend scores the 30ms average, so it isn't flooded as
+	// if it were the fastest, yet still gets tried
+	picks := latencyPicks(t, s, []*backend{slow, fresh, fast}, 2000)
+	if picks[fast] < 1800 {
+		t.Errorf("fastest backend got %d of 2000 picks next to an unsampled one", picks[fast])
+	}
+	if picks[fresh] == 0 || picks[fresh] > 200 {
+		t.Errorf("unsampled backend got %d of 2000 picks, want a few", picks[fresh])
+	}
+}
+
+func TestWeightedLeastResponseTime(t *testing.T) {
+	light, heavy, drained := newTestBackend("10.0.0.1:80", 1), newTestBackend("10.0.0.2:80", 4), newTestBackend("10.0.0.3:80", 0)
+	light.observeLatency(20 * time.Millisecond)
+	heavy.observeLatency(40 * time.Millisecond) // 10ms per unit of weight
+	drained.observeLatency(time.Millisecond)
+	s := newLeastResponseTimeStrategy(true)
+
+	picks := latencyPicks(t, s, []*backend{light, heavy, drained}, 1000)
+	if picks[heavy] < 900 {
+		t.Errorf("picks %d light, %d heavy; want the weight to outweigh the latency", picks[light], picks[heavy])
+	}
+}
+
+// countingSource is a rand.Source that counts the values drawn from it
+type countingSource struct {
+	rand.Source
+	draws int
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.Source.Uint64()
+}
+
+func TestLeastResponseTimeDrawsOnlyToExplore(t *testing.T) {
+	fast, slow := newTestBackend("10.0.0.1:80", 1), newTestBackend("10.0.0.2:80", 1)
+	fast.observeLatency(5 * time.Millisecond)
+	slow.observeLatency(50 * time.Millisecond)
+	src := &countingSource{Source: rand.NewPCG(1, 2)}
+	s := newLeastResponseTimeStrategy(false)
+	s.rng = rand.New(src)
+
+	// One draw decides whether to explore and only exploring draws again
+	const n = 1000
+	for range n {
+		if _, err := s.next(context.Background(), "", []*backend{slow, fast}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if src.draws > n*11/10 {
+		t.Errorf("%d selections drew %d random values, want about one each", n, src.draws)
+	}
+}
+
+func TestLatencyConnTimesFirstResponse(t *testing.T) {
+	be := newTestBackend("10.0.0.1:80", 1)
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &latencyConn{Conn: client, backend: be}
+
+	respond := make(chan struct{})
+	go func() {
+		buf := make([]byte, 16)
+		for range 2 {
+			server.Read(buf)
+			<-respond
+			server.Write([]byte("ok"))
+		}
+	}()
+
+	// Only the time from the request to the first byte back counts, not
+	// how long the connection sat in the pool before
+	time.Sleep(200 * time.Millisecond)
+	buf := make([]byte, 16)
+	for _, wait := range []time.Duration{20 * time.Millisecond, 200 * time.Millisecond} {
+		if _, err := conn.Write([]byte("req")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(wait)
+		respond <- struct{}{}
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := time.Duration(be.latency.Load()); got < 20*time.Millisecond || got >= 200*time.Millisecond {
+		t.Errorf("sampled latency = %v, want only the first response's 20ms", got)
+	}
+}
+
+func TestLatencyConnBackendSpeaksFirst(t *testing.T) {
+	be := newTestBackend("10.0.0.1:80", 1)
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &latencyConn{Conn: client, backend: be}
+
+	go server.Write([]byte("220 ready\r\n"))
+	if _, err := conn.Read(make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if be.latency.Load() != 0 {
+		t.Error("a greeting sent before any request was taken as a latency sample")
+	}
+}
+
+func TestLatencySampledForLatencyStrategies(t *testing.T) {
+	echo := startEcho(t)
+	cfg := loadTestConfig(t, testYAML("", echo))
+	if b := newTestBalancer(t, cfg); b.sampleLatency {
+		t.Error("latency sampled for consistent_hash")
+	}
+
+	cfg.Balancer.Strategy = config.StrategyLeastResponseTime
+	b := newTestBalancer(t, cfg)
+	conn := pipeClient(t, b)
+	sendAndExpect(t, conn, "ping")
+	conn.Close()
+	value, _ := b.backends.Load(echo)
+	if value.(*backend).latency.Load() == 0 {
+		t.Error("no latency sampled for least_response_time after a round trip")
+	}
+}
@@ -0,0 +1,39 @@
+package balancer
+
+import "context"
+
+// waitSlot blocks until a connection slot is available. It returns false if
+// the context is canceled first.
+func (b *balancer) waitSlot(ctx context.Context) bool {
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	b.inFlight.Add(1)
+	return true
+}
+
+// trySlot acquires a connection slot without blocking. It returns false if
+// the concurrent connection limit has been reached.
+func (b *balancer) trySlot() bool {
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	b.inFlight.Add(1)
+	return true
+}
+
+// releaseSlot frees a slot acquired by waitSlot or trySlot
+func (b *balancer) releaseSlot() {
+	b.inFlight.Add(-1)
+	if b.slots != nil {
+		<-b.slots
+	}
+}
@@ -0,0 +1,88 @@
+package balancer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+func TestTrySlotRejectsOverLimit(t *testing.T) {
+	cfg := loadTestConfig(t, testYAML("", "127.0.0.1:9301"))
+	cfg.Balancer.MaxConcurrentConnections = 2
+	b := newTestBalancer(t, cfg)
+
+	if !b.trySlot() || !b.trySlot() {
+		t.Fatal("trySlot failed below the limit")
+	}
+	if b.trySlot() {
+		t.Fatal("trySlot succeeded beyond the limit of 2")
+	}
+	if n := b.inFlight.Load(); n != 2 {
+		t.Errorf("%d connections in flight, want the limit of 2", n)
+	}
+
+	// A freed slot admits the next client
+	b.releaseSlot()
+	if !b.trySlot() {
+		t.Error("trySlot failed after a slot was released")
+	}
+}
+
+func TestTrySlotUnlimited(t *testing.T) {
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", "127.0.0.1:9301")))
+	for range 100 {
+		if !b.trySlot() {
+			t.Fatal("trySlot failed without a limit")
+		}
+	}
+	if n := b.inFlight.Load(); n != 100 {
+		t.Errorf("%d connections in flight, want 100", n)
+	}
+}
+
+func TestWaitSlotBlocksUntilRelease(t *testing.T) {
+	cfg := loadTestConfig(t, testYAML("", "127.0.0.1:9301"))
+	cfg.Balancer.MaxConcurrentConnections = 1
+	cfg.Balancer.OnOverflow = config.OverflowWait
+	b := newTestBalancer(t, cfg)
+
+	if !b.waitSlot(context.Background()) {
+		t.Fatal("waitSlot failed below the limit")
+	}
+	acquired := make(chan bool)
+	go func() { acquired <- b.waitSlot(context.Background()) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("second waitSlot returned before a slot freed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.releaseSlot()
+	select {
+	case ok := <-acquired:
+		if !ok {
+			t.Error("waitSlot failed after a slot freed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitSlot still blocked after a slot freed")
+	}
+}
+
+func TestWaitSlotCanceled(t *testing.T) {
+	cfg := loadTestConfig(t, testYAML("", "127.0.0.1:9301"))
+	cfg.Balancer.MaxConcurrentConnections = 1
+	b := newTestBalancer(t, cfg)
+
+	b.trySlot()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if b.waitSlot(ctx) {
+		t.Error("waitSlot acquired a slot beyond the limit after cancellation")
+	}
+	if n := b.inFlight.Load(); n != 1 {
+		t.Errorf("%d connections in flight, want 1", n)
+	}
+}
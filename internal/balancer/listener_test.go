@@ -0,0 +1,83 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/netutil"
+)
+
+func TestUnixSocketEndToEnd(t *testing.T) {
+	dir, err := os.MkdirTemp("", "lb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	backendPath := filepath.Join(dir, "backend.sock")
+	listenPath := filepath.Join(dir, "lb.sock")
+
+	ln, err := net.Listen("unix", backendPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	doc := testYAML("") + "  - {host: \"unix:" + backendPath + "\", weight: 1}\n"
+	cfg := loadTestConfig(t, doc)
+	cfg.Balancer.Listen = "unix:" + listenPath
+	b := newTestBalancer(t, cfg)
+	lbLn, err := netutil.Listen(cfg.Balancer.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.listener = lbLn
+	go func() {
+		for {
+			conn, err := lbLn.Accept()
+			if err != nil {
+				return
+			}
+			go b.handleConnection(context.Background(), conn)
+		}
+	}()
+
+	conn, err := net.Dial("unix", listenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sendAndExpect(t, conn, "over unix sockets")
+
+	if _, ok := b.backends.Load("unix:" + backendPath); !ok {
+		t.Error("backend not keyed by its socket address")
+	}
+	if got := b.hasher.Get("client"); got != "unix:"+backendPath {
+		t.Errorf("ring maps to %q, want the socket address", got)
+	}
+
+	conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	b.Shutdown(ctx)
+	if _, err := os.Stat(listenPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("listen socket after shutdown: %v, want it removed", err)
+	}
+}
@@ -0,0 +1,95 @@
+package balancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// weightedRandomStrategy picks a backend with probability proportional to
+// its effective weight, using a cumulative weight table that is rebuilt
+// lazily whenever the set of eligible backends changes
+type weightedRandomStrategy struct {
+	mu  sync.Mutex // guards rng
+	rng *rand.Rand
+
+	table      atomic.Pointer[weightTable]
+	generation atomic.Uint64
+}
+
+// weightTable is an immutable cumulative weight table
+type weightTable struct {
+	generation uint64
+	candidates []*backend // the candidates the table was built from
+	backends   []*backend
+	cumulative []int64
+	total      int64
+}
+
+func newWeightedRandomStrategy() *weightedRandomStrategy {
+	seed := uint64(time.Now().UnixNano())
+	return &weightedRandomStrategy{
+		rng: rand.New(rand.NewPCG(seed, seed>>32)),
+	}
+}
+
+func (s *weightedRandomStrategy) next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error) {
+	table := s.table.Load()
+	if table == nil || table.generation != s.generation.Load() || !table.builtFrom(candidates) {
+		table = s.build(candidates)
+	}
+	if table.total == 0 {
+		return nil, errNoCandidate
+	}
+
+	s.mu.Lock()
+	r := s.rng.Int64N(table.total)
+	s.mu.Unlock()
+
+	idx := sort.Search(len(table.cumulative), func(i int) bool {
+		return table.cumulative[i] > r
+	})
+	return table.backends[idx], nil
+}
+
+// build creates a table from the candidates and publishes it unless the
+// backend set changed again while building
+func (s *weightedRandomStrategy) build(candidates []*backend) *weightTable {
+	table := &weightTable{generation: s.generation.Load(), candidates: candidates}
+	for _, be := range candidates {
+		weight := int64(be.effectiveWeight())
+		if weight <= 0 {
+			continue
+		}
+		table.total += weight
+		table.backends = append(table.backends, be)
+		table.cumulative = append(table.cumulative, table.total)
+	}
+
+	if table.generation == s.generation.Load() {
+		s.table.Store(table)
+	}
+	return table
+}
+
+// builtFrom reports whether the table was built from the same candidates,
+// which differ between calls when retries narrow the set
+func (t *weightTable) builtFrom(candidates []*backend) bool {
+	if len(t.candidates) != len(candidates) {
+		return false
+	}
+	for i, be := range candidates {
+		if t.candidates[i] != be {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidate discards the table so the next selection rebuilds it
+func (s *weightedRandomStrategy) invalidate() {
+	s.generation.Add(1)
+}
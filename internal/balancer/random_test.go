@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+func TestWeightedRandomDistribution(t *testing.T) {
+	candidates := []*backend{
+		newTestBackend("10.0.0.1:80", 1),
+		newTestBackend("10.0.0.2:80", 3),
+		newTestBackend("10.0.0.3:80", 6),
+		newTestBackend("10.0.0.4:80", 0), // drained
+	}
+	s := newWeightedRandomStrategy()
+	s.rng = rand.New(rand.NewPCG(1, 2))
+
+	const picks = 100000
+	counts := make(map[*backend]int)
+	for range picks {
+		be, err := s.next(context.Background(), "", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[be]++
+	}
+
+	for _, be := range candidates {
+		want := float64(be.weight.Load()) / 10
+		got := float64(counts[be]) / picks
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("%s (weight %d) got %.3f of picks, want %.2f", be.addr(), be.weight.Load(), got, want)
+		}
+	}
+}
+
+func TestWeightedRandomNoWeight(t *testing.T) {
+	s := newWeightedRandomStrategy()
+	if be, err := s.next(context.Background(), "", []*backend{newTestBackend("10.0.0.1:80", 0)}); err == nil {
+		t.Errorf("all weights zero: picked %s, want none", be.addr())
+	}
+}
+
+func TestWeightedRandomExcludesUnhealthy(t *testing.T) {
+	backends := []string{"127.0.0.1:9101", "127.0.0.1:9102", "127.0.0.1:9103"}
+	cfg := loadTestConfig(t, testYAML("", backends...))
+	cfg.Balancer.Strategy = config.StrategyWeightedRandom
+	b := newTestBalancer(t, cfg)
+
+	pick := func() map[string]int {
+		seen := make(map[string]int)
+		for range 300 {
+			be, err := b.getHealthyBackend(context.Background(), "127.0.0.1:5000", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[be.addr()]++
+		}
+		return seen
+	}
+	if seen := pick(); len(seen) != 3 {
+		t.Fatalf("picked %v, want all three backends", seen)
+	}
+
+	// The cached table must not outlive the health flip
+	b.updateBackendHealth(backends[1], false)
+	if seen := pick(); seen[backends[1]] > 0 || len(seen) != 2 {
+		t.Errorf("picked %v after %s went unhealthy", seen, backends[1])
+	}
+
+	b.updateBackendHealth(backends[1], true)
+	if seen := pick(); seen[backends[1]] == 0 {
+		t.Errorf("picked %v after %s recovered", seen, backends[1])
+	}
+}
@@ -0,0 +1,85 @@
+package balancer
+
+import (
+	"io"
+	"sync"
+)
+
+// replayReader reads from a client connection while keeping a copy of the
+// bytes read, up to a limit, so they can be replayed to another backend if
+// the first one fails before responding. Once the limit is exceeded or the
+// connection is committed to a backend, recording stops for good.
+type replayReader struct {
+	src   io.Reader
+	limit int
+
+	mu        sync.Mutex
+	buf       []byte
+	overflow  bool
+	committed bool
+}
+
+// newReplayReader wraps src, recording up to limit bytes. A zero limit
+// disables replay.
+func newReplayReader(src io.Reader, limit int) *replayReader {
+	return &replayReader{
+		src:       src,
+		limit:     limit,
+		committed: limit <= 0,
+	}
+}
+
+// Read reads from the client, recording the bytes while replay is possible
+func (r *replayReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	if n > 0 {
+		r.mu.Lock()
+		if !r.committed && !r.overflow {
+			if len(r.buf)+n > r.limit {
+				r.overflow = true
+				r.buf = nil
+			} else {
+				r.buf = append(r.buf, p[:n]...)
+			}
+		}
+		r.mu.Unlock()
+	}
+	return n, err
+}
+
+// source returns the reader to copy client bytes from. With replay
+// disabled the client connection is used directly so the runtime can splice.
+func (r *replayReader) source() io.Reader {
+	if r.limit <= 0 {
+		return r.src
+	}
+	return r
+}
+
+// replayable reports whether the bytes read so far can still be replayed
+func (r *replayReader) replayable() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.committed && !r.overflow
+}
+
+// commit stops recording once a backend has started responding
+func (r *replayReader) commit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = true
+	r.buf = nil
+}
+
+// replay writes the recorded bytes to a new backend connection
+func (r *replayReader) replay(dst io.Writer) error {
+	r.mu.Lock()
+	buf := r.buf
+	r.mu.Unlock()
+
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := dst.Write(buf)
+	return err
+}
@@ -0,0 +1,136 @@
+package balancer
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// retryBalancer creates a balancer picking among the backends at random,
+// with retries enabled
+func retryBalancer(t *testing.T, bufferBytes string, backends ...string) *balancer {
+	t.Helper()
+	cfg := loadTestConfig(t, testYAML("retry: {enabled: true, max_attempts: 2, buffer_bytes: "+bufferBytes+"}\n", backends...))
+	cfg.Balancer.Strategy = config.StrategyWeightedRandom
+	return newTestBalancer(t, cfg)
+}
+
+// startCountingServer is startServer counting the connections accepted
+func startCountingServer(t *testing.T, accepted *atomic.Int64, handle func(conn net.Conn)) string {
+	return startServer(t, func(conn net.Conn) {
+		accepted.Add(1)
+		handle(conn)
+	})
+}
+
+// resetAfter runs fn on a connection and then resets it
+func resetAfter(fn func(conn net.Conn)) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		fn(conn)
+		conn.(*net.TCPConn).SetLinger(0)
+	}
+}
+
+// readRequest reads what the client sent in its first write
+func readRequest(conn net.Conn) []byte {
+	buf := make([]byte, 64<<10)
+	n, _ := conn.Read(buf)
+	return buf[:n]
+}
+
+// exchange sends msg over a new client connection and returns everything
+// read back until the connection ends, then closes it
+func exchange(t *testing.T, b *balancer, msg string) string {
+	t.Helper()
+	conn := pipeClient(t, b)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.WriteString(conn, msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("no end to the response after %q: %v", got, err)
+	}
+	return string(got)
+}
+
+func TestRetryBeforeBackendResponds(t *testing.T) {
+	var failed, served atomic.Int64
+	failing := startCountingServer(t, &failed, resetAfter(func(conn net.Conn) { readRequest(conn) }))
+	good := startCountingServer(t, &served, func(conn net.Conn) {
+		conn.Write(append([]byte("ok:"), readRequest(conn)...))
+	})
+	// Each client gets its own balancer, so none is handed a pooled
+	// connection the test server already closed
+	const clients = 20
+	for i := range clients {
+		lb := retryBalancer(t, "4096", failing, good)
+		if got := exchange(t, lb, "hello"); got != "ok:hello" {
+			t.Fatalf("client %d got %q, want the request replayed to the working backend", i, got)
+		}
+	}
+	if failed.Load() == 0 {
+		t.Fatal("the failing backend was never picked; the test proved nothing")
+	}
+	if served.Load() != clients {
+		t.Errorf("working backend served %d connections, want %d", served.Load(), clients)
+	}
+}
+
+func TestNoRetryAfterBackendResponded(t *testing.T) {
+	var accepted atomic.Int64
+	partial := resetAfter(func(conn net.Conn) {
+		readRequest(conn)
+		conn.Write([]byte("partial"))
+		time.Sleep(20 * time.Millisecond)
+	})
+	a := startCountingServer(t, &accepted, partial)
+	b := startCountingServer(t, &accepted, partial)
+	const clients = 5
+	for range clients {
+		lb := retryBalancer(t, "4096", a, b)
+		if got := exchange(t, lb, "hello"); got != "partial" {
+			t.Errorf("client got %q, want only the first backend's bytes", got)
+		}
+	}
+	if n := accepted.Load(); n != clients {
+		t.Errorf("backends accepted %d connections for %d clients; a backend that responded was retried", n, clients)
+	}
+}
+
+func TestRetryBufferExceeded(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     string
+		wantAttempt int64
+	}{
+		{"within buffer", strings.Repeat("a", 16), 2},
+		{"beyond buffer", strings.Repeat("b", 64), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accepted atomic.Int64
+			silent := resetAfter(func(conn net.Conn) {
+				// Read the whole request so the balancer has sent it all
+				buf := make([]byte, len(tt.request))
+				io.ReadFull(conn, buf)
+			})
+			a := startCountingServer(t, &accepted, silent)
+			b := startCountingServer(t, &accepted, silent)
+			lb := retryBalancer(t, "32", a, b)
+
+			if got := exchange(t, lb, tt.request); got != "" {
+				t.Errorf("client got %q from failing backends", got)
+			}
+			if n := accepted.Load(); n != tt.wantAttempt {
+				t.Errorf("backends accepted %d connections, want %d", n, tt.wantAttempt)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ritikchawla/load-balancer/internal/netutil"
+)
+
+// BackendInfo is a read-only view of a backend offered to a SelectorFunc
+type BackendInfo struct {
+	Host    string
+	Port    int
+	Weight  int // effective weight, reduced while slow start is ramping
+	Healthy bool
+	Active  int64 // connections currently proxied to the backend
+}
+
+// Addr returns the host:port address of the backend
+func (bi BackendInfo) Addr() string {
+	return netutil.JoinHostPort(bi.Host, bi.Port)
+}
+
+// SelectorFunc picks a backend for a new connection or request from the
+// eligible candidates, which are sorted by address. Returning an error
+// fails the connection as if no backend were available. In HTTP mode the
+// request is available through RequestFromContext. It replaces the
+// configured strategy entirely; the built-in strategies are not
+// SelectorFuncs and can't be wrapped or chained by one.
+type SelectorFunc func(ctx context.Context, clientAddr string, candidates []BackendInfo) (BackendInfo, error)
+
+// Options customizes a load balancer beyond what the configuration file
+// expresses
+type Options struct {
+	// Selector overrides the configured strategy when set
+	Selector SelectorFunc
+}
+
+// info returns a read-only snapshot of the backend
+func (be *backend) info() BackendInfo {
+	return BackendInfo{
+		Host:    be.host,
+		Port:    be.port,
+		Weight:  be.effectiveWeight(),
+		Healthy: be.health.Load(),
+		Active:  be.active.Load(),
+	}
+}
+
+// selectorStrategy adapts a user supplied SelectorFunc to a strategy
+type selectorStrategy struct {
+	fn SelectorFunc
+}
+
+func (s *selectorStrategy) next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error) {
+	infos := make([]BackendInfo, len(candidates))
+	for i, be := range candidates {
+		infos[i] = be.info()
+	}
+
+	picked, err := s.fn(ctx, clientAddr, infos)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only hand out backends that were offered, so a selector can't route
+	// to a backend that is unhealthy, draining or unknown
+	for _, be := range candidates {
+		if be.host == picked.Host && be.port == picked.Port {
+			return be, nil
+		}
+	}
+	return nil, fmt.Errorf("selector chose ineligible backend: %s", picked.Addr())
+}
+
+// requestContextKey carries the incoming HTTP request to selectors
+type requestContextKey struct{}
+
+// RequestFromContext returns the HTTP request being routed, if any. It is
+// only set in HTTP mode.
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(requestContextKey{}).(*http.Request)
+	return r, ok
+}
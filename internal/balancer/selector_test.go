@@ -0,0 +1,111 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// headerSelector pins a request to the backend named by its X-Backend
+// header and otherwise takes the first candidate
+func headerSelector(ctx context.Context, clientAddr string, candidates []BackendInfo) (BackendInfo, error) {
+	if r, ok := RequestFromContext(ctx); ok {
+		if want := r.Header.Get("X-Backend"); want != "" {
+			for _, c := range candidates {
+				if c.Addr() == want {
+					return c, nil
+				}
+			}
+			return BackendInfo{}, errors.New("pinned backend unavailable")
+		}
+	}
+	return candidates[0], nil
+}
+
+// namedBackend starts an HTTP backend answering with its name
+func namedBackend(t *testing.T, name string) string {
+	return startHTTPBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	}))
+}
+
+func TestSelectorPinsByHeader(t *testing.T) {
+	a, b := namedBackend(t, "a"), namedBackend(t, "b")
+	first, second := a, b
+	if b < a {
+		first, second = b, a
+	}
+	cfg := loadTestConfig(t, testYAML("", a, b))
+	cfg.Balancer.Mode = config.ModeHTTP
+	lb, err := NewWithOptions(cfg, Options{Selector: headerSelector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveHTTPBalancer(t, lb.(*balancer))
+
+	get := func(pin string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/", nil)
+		if pin != "" {
+			req.Header.Set("X-Backend", pin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	names := map[string]string{a: "a", b: "b"}
+	for range 3 {
+		if _, got := get(second); got != names[second] {
+			t.Errorf("pinned to %s, served by %q", second, got)
+		}
+		if _, got := get(""); got != names[first] {
+			t.Errorf("unpinned request served by %q, want the first candidate %q", got, names[first])
+		}
+	}
+	if code, _ := get("127.0.0.1:1"); code < 500 {
+		t.Errorf("pin to an unknown backend got %d, want a gateway error", code)
+	}
+}
+
+func TestSelectorStrategy(t *testing.T) {
+	candidates := []*backend{newTestBackend("10.0.0.1:80", 2), newTestBackend("10.0.0.2:80", 1)}
+	candidates[1].active.Add(3)
+
+	var offered []BackendInfo
+	s := &selectorStrategy{fn: func(ctx context.Context, clientAddr string, infos []BackendInfo) (BackendInfo, error) {
+		offered = infos
+		return infos[1], nil
+	}}
+	be, err := s.next(context.Background(), "192.0.2.1:5000", candidates)
+	if err != nil || be != candidates[1] {
+		t.Fatalf("next = %v, %v; want the selected backend", be, err)
+	}
+	want := BackendInfo{Host: "10.0.0.2", Port: 80, Weight: 1, Healthy: true, Active: 3}
+	if len(offered) != 2 || offered[1] != want {
+		t.Errorf("selector was offered %+v, want %+v second", offered, want)
+	}
+
+	// A selector can't route outside the candidates
+	s.fn = func(context.Context, string, []BackendInfo) (BackendInfo, error) {
+		return BackendInfo{Host: "10.9.9.9", Port: 80}, nil
+	}
+	if _, err := s.next(context.Background(), "", candidates); err == nil || !strings.Contains(err.Error(), "ineligible") {
+		t.Errorf("ineligible pick: error %v", err)
+	}
+
+	failure := errors.New("no")
+	s.fn = func(context.Context, string, []BackendInfo) (BackendInfo, error) { return BackendInfo{}, failure }
+	if _, err := s.next(context.Background(), "", candidates); !errors.Is(err, failure) {
+		t.Errorf("selector error came back as %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package balancer
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// slowStartMinFraction is the share of its weight a backend receives
+	// immediately after becoming healthy
+	slowStartMinFraction = 0.1
+	// slowStartSteps is the number of ramp updates applied to the hash
+	// ring over the slow start window
+	slowStartSteps = 10
+)
+
+// rampFraction returns the share of its configured weight the backend
+// receives: below 1 while it is ramping up after a transition to healthy,
+// 1 otherwise
+func (be *backend) rampFraction() float64 {
+	since := be.healthySince.Load()
+	if be.slowStart <= 0 || since == 0 {
+		return 1
+	}
+
+	elapsed := time.Since(time.Unix(0, since))
+	if elapsed >= be.slowStart {
+		return 1
+	}
+	return slowStartMinFraction + (1-slowStartMinFraction)*float64(elapsed)/float64(be.slowStart)
+}
+
+// effectiveWeight returns the backend's weight, reduced while the backend
+// is ramping up after a transition to healthy
+func (be *backend) effectiveWeight() int {
+	configured := int(be.weight.Load())
+	fraction := be.rampFraction()
+	if fraction >= 1 || configured <= 0 {
+		return configured
+	}
+	return max(int(float64(configured)*fraction), 1)
+}
+
+// setRingWeight gives the backend its share of the hash ring for its
+// configured weight and ramp. The ring ramps by replica positions rather
+// than whole weight units, so even a weight-1 backend starts small. It
+// reports whether the backend's share changed.
+func (b *balancer) setRingWeight(be *backend) bool {
+	return b.hasher.UpdateWeightFraction(be.addr(), int(be.weight.Load()), be.rampFraction())
+}
+
+// startSlowStart begins ramping a backend that just became healthy
+func (b *balancer) startSlowStart(be *backend) {
+	if be.slowStart <= 0 {
+		return
+	}
+	be.healthySince.Store(time.Now().UnixNano())
+	b.setRingWeight(be)
+}
+
+// rampWeights periodically moves the ring weight of ramping backends
+// towards their configured weight
+func (b *balancer) rampWeights(ctx context.Context) {
+	window := time.Duration(b.cfg.SlowStart.Duration)
+	if window <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(window / slowStartSteps)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.rampStep()
+		}
+	}
+}
+
+// rampStep moves the ring share of every ramping backend to its current
+// ramp, ending the ramp of those that reached their configured weight. The
+// strategy is only told when a share actually changed.
+func (b *balancer) rampStep() {
+	b.backends.Range(func(_, value any) bool {
+		be := value.(*backend)
+		since := be.healthySince.Load()
+		if since == 0 {
+			return true
+		}
+
+		changed := b.setRingWeight(be)
+		if be.rampFraction() >= 1 {
+			be.healthySince.CompareAndSwap(since, 0)
+		}
+		if changed {
+			b.backendsChanged()
+		}
+		return true
+	})
+}
@@ -0,0 +1,123 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// share returns the fraction of n client addresses routed to addr
+func share(t *testing.T, b *balancer, addr string, n int) float64 {
+	t.Helper()
+	hits := 0
+	for i := range n {
+		be, err := b.getHealthyBackend(context.Background(), fmt.Sprintf("10.1.%d.%d:4000", i/250, i%250), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if be.addr() == addr {
+			hits++
+		}
+	}
+	return float64(hits) / float64(n)
+}
+
+// recoverFor marks the backend at addr as having turned healthy the given
+// time ago and moves its ring share along the ramp
+func recoverFor(t *testing.T, b *balancer, addr string, ago time.Duration) {
+	t.Helper()
+	value, ok := b.backends.Load(addr)
+	if !ok {
+		t.Fatalf("no backend %s", addr)
+	}
+	value.(*backend).healthySince.Store(time.Now().Add(-ago).UnixNano())
+	b.rampStep()
+}
+
+func TestSlowStartRampsTrafficShare(t *testing.T) {
+	const cold, warm = "127.0.0.1:9101", "127.0.0.1:9102"
+	cfg := loadTestConfig(t, testYAML("slow_start: {duration: 10s}\n", cold, warm))
+	for i := range cfg.Backends {
+		cfg.Backends[i].Weight = 10
+	}
+	b := newTestBalancer(t, cfg)
+
+	b.updateBackendHealth(cold, false)
+	b.updateBackendHealth(cold, true)
+	shares := []float64{share(t, b, cold, 2000)}
+	for _, ago := range []time.Duration{4 * time.Second, 8 * time.Second, 12 * time.Second} {
+		recoverFor(t, b, cold, ago)
+		shares = append(shares, share(t, b, cold, 2000))
+	}
+
+	if last := shares[len(shares)-1]; shares[0] > last/2 {
+		t.Errorf("share right after recovery = %.2f, want a small fraction of the final %.2f", shares[0], last)
+	}
+	for i := 1; i < len(shares); i++ {
+		if shares[i] < shares[i-1] {
+			t.Errorf("share fell from %.2f to %.2f while ramping", shares[i-1], shares[i])
+		}
+	}
+	if last := shares[len(shares)-1]; last < 0.35 {
+		t.Errorf("share after the window = %.2f, want about half", last)
+	}
+	if be, _ := b.backends.Load(cold); be.(*backend).healthySince.Load() != 0 {
+		t.Error("ramp did not end after the window")
+	}
+}
+
+func TestSlowStartRampsWeightOne(t *testing.T) {
+	const cold, warm = "127.0.0.1:9101", "127.0.0.1:9102"
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("slow_start: {duration: 10s}\n", cold, warm)))
+
+	b.updateBackendHealth(cold, false)
+	b.updateBackendHealth(cold, true)
+	first := share(t, b, cold, 2000)
+	if first > 0.25 {
+		t.Errorf("weight-1 backend took %.2f of traffic right after recovery, want a small share", first)
+	}
+
+	recoverFor(t, b, cold, 5*time.Second)
+	if mid := share(t, b, cold, 2000); mid <= first {
+		t.Errorf("share halfway through the window = %.2f, want more than the initial %.2f", mid, first)
+	}
+}
+
+// invalidationCounter counts the invalidations of the strategy it wraps
+type invalidationCounter struct {
+	strategy
+	invalidations int
+}
+
+func (s *invalidationCounter) invalidate() {
+	s.invalidations++
+}
+
+func TestRampStepInvalidatesOnlyOnChange(t *testing.T) {
+	const cold, warm = "127.0.0.1:9105", "127.0.0.1:9106"
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("slow_start: {duration: 10s}\n", cold, warm)))
+	counter := &invalidationCounter{strategy: b.strategy}
+	b.strategy = counter
+
+	b.updateBackendHealth(cold, false)
+	b.updateBackendHealth(cold, true)
+	counter.invalidations = 0
+
+	b.rampStep()
+	if counter.invalidations != 0 {
+		t.Errorf("ramp step without elapsed time invalidated %d times, want 0", counter.invalidations)
+	}
+	recoverFor(t, b, cold, 2*time.Second)
+	if counter.invalidations != 1 {
+		t.Errorf("ramp step that changed the ring invalidated %d times, want 1", counter.invalidations)
+	}
+}
+
+func TestSlowStartOffByDefault(t *testing.T) {
+	be := newTestBackend("127.0.0.1:9101", 10)
+	be.healthySince.Store(time.Now().UnixNano())
+	if got := be.effectiveWeight(); got != 10 {
+		t.Errorf("effective weight without slow start = %d, want 10", got)
+	}
+}
@@ -0,0 +1,65 @@
+package balancer
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+const (
+	// sniffTimeout bounds how long we wait for a client's first bytes
+	sniffTimeout = time.Second
+	// sniffSize is the number of bytes read to recognize a protocol
+	sniffSize = 8
+)
+
+// httpMethods are the request line prefixes that identify an HTTP/1.x client
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("HEAD "), []byte("POST "), []byte("PUT "),
+	[]byte("DELETE "), []byte("PATCH "), []byte("OPTIONS "), []byte("CONNECT "),
+	[]byte("TRACE "),
+}
+
+// looksLikeHTTP reports whether data starts like an HTTP/1.x request line.
+// A short read matching the start of a method also counts.
+func looksLikeHTTP(data []byte) bool {
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(data, method) || (len(data) >= 3 && bytes.HasPrefix(method, data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffHTTP reads the client's first bytes and reports whether they look
+// like an HTTP request
+func sniffHTTP(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, sniffSize)
+	n, _ := conn.Read(buf)
+	return looksLikeHTTP(buf[:n])
+}
+
+// serviceUnavailableResponse is written to HTTP clients when no backend can
+// take the connection
+const serviceUnavailableResponse = "HTTP/1.1 503 Service Unavailable\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Length: 20\r\n" +
+	"Connection: close\r\n" +
+	"\r\n" +
+	"no backend available"
+
+// rejectNoBackend responds to a client for which no backend is available,
+// according to the configured no-backend response
+func (b *balancer) rejectNoBackend(conn net.Conn) {
+	if b.cfg.Balancer.NoBackendResponse != config.NoBackendHTTP503 {
+		return
+	}
+	if sniffHTTP(conn) {
+		conn.Write([]byte(serviceUnavailableResponse))
+	}
+}
@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLooksLikeHTTP(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"GET / HT", true},
+		{"POST /up", true},
+		{"OPTIONS ", true},
+		{"DEL", true}, // a short read of a method
+		{"GE", false},
+		{"\x16\x03\x01\x02\x00", false}, // TLS ClientHello
+		{"SSH-2.0-", false},
+		{"get / HT", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := looksLikeHTTP([]byte(tt.data)); got != tt.want {
+			t.Errorf("looksLikeHTTP(%q) = %t, want %t", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestNoBackendResponse(t *testing.T) {
+	tests := []struct {
+		mode     string
+		request  string
+		wantNone bool // closed without a response rather than a 503
+	}{
+		{"http503", "GET / HTTP/1.1\r\nHost: lb\r\n\r\n", false},
+		{"http503", "\x16\x03\x01\x00\x05hello", true}, // not HTTP
+		{"reset", "GET / HTTP/1.1\r\nHost: lb\r\n\r\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%q", tt.mode, tt.request[:3]), func(t *testing.T) {
+			echo := startEcho(t)
+			cfg := loadTestConfig(t, testYAML("", echo))
+			cfg.Balancer.NoBackendResponse = tt.mode
+			b := newTestBalancer(t, cfg)
+			b.updateBackendHealth(echo, false)
+
+			conn, server := net.Pipe()
+			defer conn.Close()
+			go b.handleConnection(context.Background(), server)
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			go io.WriteString(conn, tt.request)
+
+			if tt.wantNone {
+				if got, err := io.ReadAll(conn); len(got) != 0 {
+					t.Errorf("client got %q, %v; want the connection closed without a response", got, err)
+				}
+				return
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status %s, want 503", resp.Status)
+			}
+			if string(body) != "no backend available" {
+				t.Errorf("body %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+	"github.com/ritikchawla/load-balancer/internal/hashing"
+)
+
+// strategy picks a backend for a new connection from the eligible candidates.
+// Candidates are sorted by address so that ties break deterministically.
+// The built-in strategies implement it directly, since they need backend
+// state a SelectorFunc doesn't see, such as the hash ring and latency;
+// a SelectorFunc is a separate, public hook adapted to it by
+// selectorStrategy.
+type strategy interface {
+	next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error)
+}
+
+// errNoCandidate is returned by strategies that find nothing to pick
+var errNoCandidate = errors.New("no eligible backend")
+
+// invalidator is implemented by strategies that cache state derived from the
+// eligible backends and must be told when health or weights change
+type invalidator interface {
+	invalidate()
+}
+
+// newStrategy creates the strategy with the given configured name
+func newStrategy(name string, hasher *hashing.ConsistentHasher) (strategy, error) {
+	switch name {
+	case config.StrategyConsistentHash:
+		return &consistentHashStrategy{hasher: hasher}, nil
+	case config.StrategyWeightedLeastConnections:
+		return &weightedLeastConnStrategy{}, nil
+	case config.StrategyWeightedRandom:
+		return newWeightedRandomStrategy(), nil
+	case config.StrategyLeastResponseTime:
+		return newLeastResponseTimeStrategy(false), nil
+	case config.StrategyWeightedLeastResponseTime:
+		return newLeastResponseTimeStrategy(true), nil
+	default:
+		return nil, fmt.Errorf("unknown strategy: %q", name)
+	}
+}
+
+// consistentHashStrategy routes a key to the backend owning it on the ring
+type consistentHashStrategy struct {
+	hasher *hashing.ConsistentHasher
+}
+
+func (s *consistentHashStrategy) next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error) {
+	byAddr := make(map[string]*backend, len(candidates))
+	for _, be := range candidates {
+		byAddr[be.addr()] = be
+	}
+
+	// Walk the ring past nodes that aren't candidates, so keys owned by an
+	// unhealthy or other-tier backend move to the next eligible one
+	addr := s.hasher.GetWhere(clientAddr, func(node string) bool {
+		_, ok := byAddr[node]
+		return ok
+	})
+	if be, ok := byAddr[addr]; ok {
+		return be, nil
+	}
+	return nil, errNoCandidate
+}
+
+// weightedLeastConnStrategy picks the backend with the lowest ratio of
+// active connections to effective weight
+type weightedLeastConnStrategy struct{}
+
+func (s *weightedLeastConnStrategy) next(ctx context.Context, clientAddr string, candidates []*backend) (*backend, error) {
+	var best *backend
+	var bestActive, bestWeight int64
+	for _, be := range candidates {
+		// Zero-weight backends are filtered out before selection, but guard
+		// anyway since the ratio is undefined for them
+		weight := int64(be.effectiveWeight())
+		if weight <= 0 {
+			continue
+		}
+		active := be.active.Load()
+		// Compare active/weight ratios without dividing:
+		// a/wa < b/wb  <=>  a*wb < b*wa
+		if best == nil || active*bestWeight < bestActive*weight {
+			best = be
+			bestActive = active
+			bestWeight = weight
+		}
+	}
+	if best == nil {
+		return nil, errNoCandidate
+	}
+	return best, nil
+}
@@ -0,0 +1,109 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestWeightedLeastConnectionsDistribution(t *testing.T) {
+	candidates := []*backend{
+		newTestBackend("10.0.0.1:80", 1),
+		newTestBackend("10.0.0.2:80", 2),
+		newTestBackend("10.0.0.3:80", 4),
+	}
+	s := &weightedLeastConnStrategy{}
+
+	// Open 70 concurrent connections, then keep closing a random one and
+	// opening another in its place
+	var open []*backend
+	connect := func() {
+		be, err := s.next(context.Background(), "192.0.2.1:5000", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		be.active.Add(1)
+		open = append(open, be)
+	}
+	for range 70 {
+		connect()
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		i := rng.IntN(len(open))
+		open[i].active.Add(-1)
+		open[i] = open[len(open)-1]
+		open = open[:len(open)-1]
+		connect()
+	}
+
+	for _, be := range candidates {
+		want := 10 * be.weight.Load()
+		if got := be.active.Load(); got < want-1 || got > want+1 {
+			t.Errorf("%s (weight %d) has %d active connections, want %d±1", be.addr(), be.weight.Load(), got, want)
+		}
+	}
+}
+
+func TestWeightedLeastConnectionsTiesAndZeroWeight(t *testing.T) {
+	zero := newTestBackend("10.0.0.1:80", 0)
+	a := newTestBackend("10.0.0.2:80", 1)
+	b := newTestBackend("10.0.0.3:80", 1)
+	s := &weightedLeastConnStrategy{}
+
+	for range 3 {
+		if be, _ := s.next(context.Background(), "192.0.2.1:5000", []*backend{zero, a, b}); be != a {
+			t.Fatalf("tie picked %s, want the first candidate %s", be.addr(), a.addr())
+		}
+	}
+
+	if _, err := s.next(context.Background(), "192.0.2.1:5000", []*backend{zero}); err == nil {
+		t.Error("picked a zero-weight backend")
+	}
+}
+
+func TestBackupTier(t *testing.T) {
+	primaries := []string{"127.0.0.1:9101", "127.0.0.1:9102"}
+	const backup = "127.0.0.1:9199"
+	doc := testYAML("", primaries...) + "  - {host: 127.0.0.1, port: 9199, weight: 1, backup: true}\n"
+	b := newTestBalancer(t, loadTestConfig(t, doc))
+
+	picks := func() map[string]int {
+		seen := make(map[string]int)
+		for i := range 200 {
+			be, err := b.getHealthyBackend(context.Background(), fmt.Sprintf("10.1.0.%d:5000", i), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[be.addr()]++
+		}
+		return seen
+	}
+
+	if seen := picks(); seen[backup] > 0 {
+		t.Errorf("backup picked %d times with primaries healthy", seen[backup])
+	}
+
+	b.updateBackendHealth(primaries[0], false)
+	if seen := picks(); seen[backup] > 0 || seen[primaries[1]] != 200 {
+		t.Errorf("picks %v with one primary healthy, want all on %s", seen, primaries[1])
+	}
+
+	b.updateBackendHealth(primaries[1], false)
+	if seen := picks(); seen[backup] != 200 {
+		t.Errorf("picks %v with all primaries down, want all on the backup", seen)
+	}
+
+	// An unhealthy backup isn't used either
+	b.updateBackendHealth(backup, false)
+	if _, err := b.getHealthyBackend(context.Background(), "10.1.0.1:5000", nil); err == nil {
+		t.Error("picked a backend with every backend down")
+	}
+	b.updateBackendHealth(backup, true)
+
+	b.updateBackendHealth(primaries[0], true)
+	if seen := picks(); seen[backup] > 0 || seen[primaries[0]] != 200 {
+		t.Errorf("picks %v after %s recovered, want all back on it", seen, primaries[0])
+	}
+}
@@ -0,0 +1,178 @@
+package balancer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for TLS tests and writes them as PEM files
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+	file string // PEM bundle holding the CA certificate
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	ca := &testCA{dir: t.TempDir(), pool: x509.NewCertPool()}
+	ca.cert, ca.key, ca.file = ca.issue(t, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	ca.pool.AddCert(ca.cert)
+	return ca
+}
+
+// issue signs tmpl with the CA, or self-signs it before the CA exists, and
+// writes the certificate to name.pem and its key to name-key.pem
+func (ca *testCA) issue(t *testing.T, name string, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := tmpl, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(ca.dir, name+".pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, filepath.Join(ca.dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+	return cert, key, certFile
+}
+
+// leaf issues a certificate for TLS servers or clients named name
+func (ca *testCA) leaf(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	cert, key, _ := ca.issue(t, name, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		DNSNames:    []string{name},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	})
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startMTLSEcho starts an echo server that requires a client certificate
+// signed by ca, reporting each client's certificate name on names
+func startMTLSEcho(t *testing.T, ca *testCA, names chan<- string) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{ca.leaf(t, "backend.test", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tc := conn.(*tls.Conn)
+				if err := tc.Handshake(); err != nil {
+					return
+				}
+				select {
+				case names <- tc.ConnectionState().PeerCertificates[0].Subject.CommonName:
+				default:
+				}
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// backendTLSYAML configures a single backend at addr with the given TLS
+// section
+func backendTLSYAML(addr, tlsSection string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	doc := testYAML("")
+	return doc + fmt.Sprintf("  - {host: %s, port: %s, weight: 1, tls: %s}\n", host, port, tlsSection)
+}
+
+func TestBackendMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	ca.leaf(t, "balancer.test", x509.ExtKeyUsageClientAuth)
+	names := make(chan string, 1)
+	backend := startMTLSEcho(t, ca, names)
+
+	tlsSection := fmt.Sprintf("{enabled: true, ca_file: %s, cert_file: %s, key_file: %s, server_name: backend.test}",
+		ca.file, filepath.Join(ca.dir, "balancer.test.pem"), filepath.Join(ca.dir, "balancer.test-key.pem"))
+	b := newTestBalancer(t, loadTestConfig(t, backendTLSYAML(backend, tlsSection)))
+
+	conn := pipeClient(t, b)
+	sendAndExpect(t, conn, "over mTLS")
+	select {
+	case name := <-names:
+		if name != "balancer.test" {
+			t.Errorf("backend saw client certificate %q, want balancer.test", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend saw no client certificate")
+	}
+}
+
+func TestBackendTLSWithoutClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	backend := startMTLSEcho(t, ca, make(chan string, 1))
+
+	tlsSection := fmt.Sprintf("{enabled: true, ca_file: %s, server_name: backend.test}", ca.file)
+	b := newTestBalancer(t, loadTestConfig(t, backendTLSYAML(backend, tlsSection)))
+
+	// The backend refuses the handshake, so nothing is proxied
+	conn := pipeClient(t, b)
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	io.WriteString(conn, "rejected")
+	if got, _ := io.ReadAll(conn); strings.Contains(string(got), "rejected") {
+		t.Errorf("client got %q through a backend requiring a client certificate", got)
+	}
+}
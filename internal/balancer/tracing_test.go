@@ -0,0 +1,90 @@
+package balancer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider exporting to memory for the rest
+// of the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// awaitSpan waits for a span with the given name to end and returns it
+func awaitSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %s span ended", name)
+	return tracetest.SpanStub{}
+}
+
+func spanAttrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestConnectionSpans(t *testing.T) {
+	exporter := recordSpans(t)
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.handleConnection(context.Background(), server)
+	}()
+	sendAndExpect(t, client, "traced")
+	client.Close()
+	<-done
+
+	span := awaitSpan(t, exporter, "handle_connection")
+	attrs := spanAttrs(span)
+	if got := attrs["client.address"].AsString(); got != server.RemoteAddr().String() {
+		t.Errorf("client.address = %q, want %q", got, server.RemoteAddr())
+	}
+	if got := attrs["backend.address"].AsString(); got != echo {
+		t.Errorf("backend.address = %q, want %q", got, echo)
+	}
+	if sent := attrs["bytes.sent"].AsInt64(); sent != 6 {
+		t.Errorf("bytes sent = %d, want 6", sent)
+	}
+
+	for _, name := range []string{"select_backend", "dial_backend", "proxy"} {
+		child := awaitSpan(t, exporter, name)
+		if child.Parent.SpanID() != span.SpanContext.SpanID() {
+			t.Errorf("%s span is not a child of handle_connection", name)
+		}
+	}
+}
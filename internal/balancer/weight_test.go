@@ -0,0 +1,56 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// selections counts the backends picked for n distinct clients
+func selections(t *testing.T, b *balancer, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := range n {
+		be, err := b.getHealthyBackend(context.Background(), fmt.Sprintf("10.2.%d.%d:4000", i/250, i%250), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[be.addr()]++
+	}
+	return counts
+}
+
+func TestZeroWeightGetsNoNewConnections(t *testing.T) {
+	const drained, serving = "127.0.0.1:9201", "127.0.0.1:9202"
+	cfg := loadTestConfig(t, testYAML("", drained, serving))
+	cfg.Backends[0].Weight = 0
+	b := newTestBalancer(t, cfg)
+
+	if got := selections(t, b, 500)[drained]; got != 0 {
+		t.Fatalf("zero-weight backend got %d of 500 selections", got)
+	}
+
+	// Raising the weight makes it eligible again
+	b.updateBackendWeight(drained, 1)
+	if got := selections(t, b, 500)[drained]; got == 0 {
+		t.Error("backend got no selections after its weight was raised")
+	}
+}
+
+func TestZeroWeightKeepsExistingConnections(t *testing.T) {
+	echo := startEcho(t)
+	b := newTestBalancer(t, loadTestConfig(t, testYAML("", echo)))
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go b.handleConnection(context.Background(), server)
+
+	sendAndExpect(t, client, "before")
+	b.updateBackendWeight(echo, 0)
+	sendAndExpect(t, client, "after")
+
+	if _, err := b.getHealthyBackend(context.Background(), "10.0.0.1:5000", nil); err == nil {
+		t.Error("backend drained to weight 0 is still selected")
+	}
+}
@@ -1,88 +1,396 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
+
+	"github.com/ritikchawla/load-balancer/internal/netutil"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Balancer BalancerConfig  `yaml:"balancer"`
-	Backends []BackendConfig `yaml:"backends"`
-	Pool     PoolConfig      `yaml:"pool"`
+	Balancer  BalancerConfig  `yaml:"balancer" json:"balancer" toml:"balancer"`
+	Backends  []BackendConfig `yaml:"backends" json:"backends" toml:"backends"`
+	Pool      PoolConfig      `yaml:"pool" json:"pool" toml:"pool"`
+	SlowStart SlowStartConfig `yaml:"slow_start" json:"slow_start" toml:"slow_start"`
+	KeepAlive KeepAliveConfig `yaml:"keepalive" json:"keepalive" toml:"keepalive"`
+	Tracing   TracingConfig   `yaml:"tracing" json:"tracing" toml:"tracing"`
+	Proxy     ProxyConfig     `yaml:"proxy" json:"proxy" toml:"proxy"`
+	Health    HealthConfig    `yaml:"health" json:"health" toml:"health"`
+	Retry     RetryConfig     `yaml:"retry" json:"retry" toml:"retry"`
 }
 
+// Backend selection strategies
+const (
+	StrategyConsistentHash            = "consistent_hash"
+	StrategyWeightedLeastConnections  = "weighted_least_connections"
+	StrategyWeightedRandom            = "weighted_random"
+	StrategyLeastResponseTime         = "least_response_time"
+	StrategyWeightedLeastResponseTime = "weighted_least_response_time"
+)
+
+// Responses sent to clients when no backend is available in TCP mode
+const (
+	NoBackendReset   = "reset"
+	NoBackendHTTP503 = "http503"
+)
+
+// Proxy modes
+const (
+	ModeTCP  = "tcp"
+	ModeHTTP = "http"
+)
+
+// Behaviors when the concurrent connection limit is reached
+const (
+	OverflowReject = "reject"
+	OverflowWait   = "wait"
+)
+
 // BalancerConfig holds the load balancer specific configuration
 type BalancerConfig struct {
-	Port                int           `yaml:"port"`
-	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
-	FailureThreshold    float64       `yaml:"failure_threshold"`
+	Port int `yaml:"port" json:"port" toml:"port"`
+	// Listen is the address to accept clients on, either host:port or
+	// unix:/path/to.sock; defaults to all interfaces on Port
+	Listen              string   `yaml:"listen" json:"listen" toml:"listen"`
+	Mode                string   `yaml:"mode" json:"mode" toml:"mode"`
+	HealthCheckInterval Duration `yaml:"health_check_interval" json:"health_check_interval" toml:"health_check_interval"`
+	// Deprecated: FailureThreshold is the phi-accrual threshold; use
+	// HealthConfig.PhiThreshold instead
+	FailureThreshold float64 `yaml:"failure_threshold" json:"failure_threshold" toml:"failure_threshold"`
+
+	Strategy string `yaml:"strategy" json:"strategy" toml:"strategy"`
+
+	// MaxConcurrentConnections bounds the number of client connections
+	// handled at once; zero means unlimited. OnOverflow selects whether
+	// excess connections are rejected or wait for a free slot.
+	MaxConcurrentConnections int    `yaml:"max_concurrent_connections" json:"max_concurrent_connections" toml:"max_concurrent_connections"`
+	OnOverflow               string `yaml:"on_overflow" json:"on_overflow" toml:"on_overflow"`
+
+	// NoBackendResponse selects what TCP clients get when no backend is
+	// available: a plain close, or a 503 if the client speaks HTTP
+	NoBackendResponse string `yaml:"no_backend_response" json:"no_backend_response" toml:"no_backend_response"`
+
+	// MinHealthyBackends is the number of healthy backends required for
+	// the admin /ready endpoint to report ready
+	MinHealthyBackends int `yaml:"min_healthy_backends" json:"min_healthy_backends" toml:"min_healthy_backends"`
 }
 
-// BackendConfig represents a single backend server configuration
+// BackendConfig represents a single backend server configuration. A weight
+// of zero keeps the backend registered and health checked but sends it no
+// new connections.
 type BackendConfig struct {
-	Host   string `yaml:"host"`
-	Port   int    `yaml:"port"`
-	Weight int    `yaml:"weight"`
+	// Host is a hostname or IP, or unix:/path/to.sock for a Unix domain
+	// socket, in which case Port is ignored
+	Host   string           `yaml:"host" json:"host" toml:"host"`
+	Port   int              `yaml:"port" json:"port" toml:"port"`
+	Weight int              `yaml:"weight" json:"weight" toml:"weight"`
+	TLS    BackendTLSConfig `yaml:"tls" json:"tls" toml:"tls"`
+
+	// Backup backends only receive traffic when no primary is available
+	Backup bool `yaml:"backup" json:"backup" toml:"backup"`
+}
+
+// Addr returns the address the backend is dialed and keyed by
+func (bc BackendConfig) Addr() string {
+	return netutil.JoinHostPort(bc.Host, bc.Port)
+}
+
+// BackendTLSConfig enables TLS on connections to a backend. The CA bundle
+// verifies the backend's certificate and the optional client certificate
+// and key are presented for mutual TLS.
+type BackendTLSConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled" toml:"enabled"`
+	CAFile     string `yaml:"ca_file" json:"ca_file" toml:"ca_file"`
+	CertFile   string `yaml:"cert_file" json:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" json:"key_file" toml:"key_file"`
+	ServerName string `yaml:"server_name" json:"server_name" toml:"server_name"`
 }
 
 // PoolConfig represents connection pool configuration
 type PoolConfig struct {
-	MaxIdle     int           `yaml:"max_idle"`
-	MaxActive   int           `yaml:"max_active"`
-	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	MaxIdle     int      `yaml:"max_idle" json:"max_idle" toml:"max_idle"`
+	MaxActive   int      `yaml:"max_active" json:"max_active" toml:"max_active"`
+	IdleTimeout Duration `yaml:"idle_timeout" json:"idle_timeout" toml:"idle_timeout"`
+}
+
+// SlowStartConfig controls the traffic ramp-up of backends that have just
+// become healthy. A zero duration disables slow start.
+type SlowStartConfig struct {
+	Duration Duration `yaml:"duration" json:"duration" toml:"duration"`
+}
+
+// KeepAliveConfig controls TCP keep-alive on client and backend connections.
+// When disabled the operating system and Go runtime defaults apply.
+type KeepAliveConfig struct {
+	Enabled bool     `yaml:"enabled" json:"enabled" toml:"enabled"`
+	Period  Duration `yaml:"period" json:"period" toml:"period"`
+}
+
+// TracingConfig controls OpenTelemetry tracing of proxied connections
+type TracingConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled" toml:"enabled"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" json:"otlp_endpoint" toml:"otlp_endpoint"`
+}
+
+// Health check modes
+const (
+	HealthModePhi = "phi"
+	HealthModeTCP = "tcp"
+)
+
+// Default health check thresholds
+const (
+	DefaultPhiThreshold        = 8.0
+	DefaultConsecutiveFailures = 3
+)
+
+// HealthConfig controls how backend health is determined and how probes are
+// scheduled. In phi mode a backend is unhealthy once the phi-accrual
+// suspicion level reaches PhiThreshold; in tcp mode once ConsecutiveFailures
+// probes in a row have failed. With jitter each backend is probed at a fixed
+// offset within the interval; a non-zero seed makes the offsets reproducible.
+type HealthConfig struct {
+	Mode                string  `yaml:"mode" json:"mode" toml:"mode"`
+	PhiThreshold        float64 `yaml:"phi_threshold" json:"phi_threshold" toml:"phi_threshold"`
+	ConsecutiveFailures int     `yaml:"consecutive_failures" json:"consecutive_failures" toml:"consecutive_failures"`
+	MaxConcurrentChecks int     `yaml:"max_concurrent_checks" json:"max_concurrent_checks" toml:"max_concurrent_checks"`
+	Jitter              bool    `yaml:"jitter" json:"jitter" toml:"jitter"`
+	JitterSeed          int64   `yaml:"jitter_seed" json:"jitter_seed" toml:"jitter_seed"`
+}
+
+// RetryConfig controls safe retries in TCP mode. Up to BufferBytes of the
+// client's initial payload are kept so that, if a backend fails before
+// sending any response, the connection can be moved to another backend and
+// the payload replayed. Clients that send more before the backend responds
+// are not retried.
+type RetryConfig struct {
+	Enabled     bool `yaml:"enabled" json:"enabled" toml:"enabled"`
+	BufferBytes int  `yaml:"buffer_bytes" json:"buffer_bytes" toml:"buffer_bytes"`
+	MaxAttempts int  `yaml:"max_attempts" json:"max_attempts" toml:"max_attempts"`
+}
+
+// Bounds for the proxy copy buffer size
+const (
+	MinProxyBufferSize = 4 << 10
+	MaxProxyBufferSize = 16 << 20
+)
+
+// ProxyConfig controls how bytes are copied between client and backend.
+// A zero buffer size uses the runtime's default copy path, which allows
+// zero-copy splicing between TCP connections.
+type ProxyConfig struct {
+	BufferSize int `yaml:"buffer_size" json:"buffer_size" toml:"buffer_size"`
 }
 
-// Load reads and parses the configuration file
+// Load reads and parses the configuration file, applying any environment
+// variable overrides
 func Load(path string) (*Config, error) {
+	return LoadWithOverrides(path, Overrides{})
+}
+
+// LoadWithOverrides reads and parses the configuration file, then applies
+// environment variable and command line overrides. Precedence is
+// flags > environment > file.
+func LoadWithOverrides(path string, overrides Overrides) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := decode(path, data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
 
+	if err := applyEnv(&cfg); err != nil {
+		return nil, fmt.Errorf("applying environment overrides: %w", err)
+	}
+
+	if err := overrides.apply(&cfg); err != nil {
+		return nil, fmt.Errorf("applying flag overrides: %w", err)
+	}
+
+	setDefaults(&cfg)
+
 	if err := validate(&cfg); err != nil {
-		return nil, fmt.Errorf("validating config: %w", err)
+		return nil, fmt.Errorf("validating config (%s): %w", precedenceNote, err)
 	}
 
 	return &cfg, nil
 }
 
+// decode parses config data using the format implied by the file extension.
+// Unknown extensions are parsed as YAML.
+func decode(path string, data []byte, cfg *Config) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, cfg)
+	case ".json":
+		return json.Unmarshal(data, cfg)
+	case ".toml":
+		return toml.Unmarshal(data, cfg)
+	default:
+		log.Printf("Unknown config file extension %q, parsing as YAML", ext)
+		return yaml.Unmarshal(data, cfg)
+	}
+}
+
+// setDefaults fills in optional settings that were left unset
+func setDefaults(cfg *Config) {
+	if cfg.Balancer.Mode == "" {
+		cfg.Balancer.Mode = ModeTCP
+	}
+
+	if cfg.Balancer.Strategy == "" {
+		cfg.Balancer.Strategy = StrategyConsistentHash
+	}
+
+	if cfg.Balancer.OnOverflow == "" {
+		cfg.Balancer.OnOverflow = OverflowReject
+	}
+
+	if cfg.Balancer.NoBackendResponse == "" {
+		cfg.Balancer.NoBackendResponse = NoBackendReset
+	}
+
+	if cfg.Balancer.MinHealthyBackends == 0 {
+		cfg.Balancer.MinHealthyBackends = 1
+	}
+
+	if cfg.Retry.BufferBytes == 0 {
+		cfg.Retry.BufferBytes = 4096
+	}
+
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry.MaxAttempts = 2
+	}
+
+	if cfg.Health.Mode == "" {
+		cfg.Health.Mode = HealthModePhi
+	}
+
+	if cfg.Balancer.FailureThreshold != 0 {
+		log.Printf("balancer.failure_threshold is deprecated, use health.phi_threshold instead")
+		if cfg.Health.PhiThreshold == 0 {
+			cfg.Health.PhiThreshold = cfg.Balancer.FailureThreshold
+		}
+	}
+
+	if cfg.Health.PhiThreshold == 0 {
+		cfg.Health.PhiThreshold = DefaultPhiThreshold
+	}
+
+	if cfg.Health.ConsecutiveFailures == 0 {
+		cfg.Health.ConsecutiveFailures = DefaultConsecutiveFailures
+	}
+
+	if cfg.Balancer.Listen == "" {
+		cfg.Balancer.Listen = fmt.Sprintf(":%d", cfg.Balancer.Port)
+	}
+
+	if cfg.KeepAlive.Enabled && cfg.KeepAlive.Period == 0 {
+		cfg.KeepAlive.Period = Duration(15 * time.Second)
+	}
+}
+
 // validate checks if the configuration is valid
 func validate(cfg *Config) error {
-	if cfg.Balancer.Port <= 0 {
-		return fmt.Errorf("invalid port: %d", cfg.Balancer.Port)
+	if err := validateListen(cfg.Balancer.Listen); err != nil {
+		return err
 	}
 
 	if cfg.Balancer.HealthCheckInterval <= 0 {
 		return fmt.Errorf("invalid health check interval: %v", cfg.Balancer.HealthCheckInterval)
 	}
 
-	if cfg.Balancer.FailureThreshold <= 0 {
-		return fmt.Errorf("invalid failure threshold: %v", cfg.Balancer.FailureThreshold)
+	switch cfg.Health.Mode {
+	case HealthModePhi, HealthModeTCP:
+	default:
+		return fmt.Errorf("unknown health mode: %q", cfg.Health.Mode)
+	}
+
+	if cfg.Health.PhiThreshold <= 0 {
+		return fmt.Errorf("invalid phi threshold: %v", cfg.Health.PhiThreshold)
+	}
+
+	if cfg.Health.ConsecutiveFailures <= 0 {
+		return fmt.Errorf("invalid consecutive failures: %d", cfg.Health.ConsecutiveFailures)
+	}
+
+	switch cfg.Balancer.Mode {
+	case ModeTCP, ModeHTTP:
+	default:
+		return fmt.Errorf("unknown mode: %q", cfg.Balancer.Mode)
+	}
+
+	switch cfg.Balancer.Strategy {
+	case StrategyConsistentHash, StrategyWeightedLeastConnections, StrategyWeightedRandom,
+		StrategyLeastResponseTime, StrategyWeightedLeastResponseTime:
+	default:
+		return fmt.Errorf("unknown strategy: %q", cfg.Balancer.Strategy)
+	}
+
+	if cfg.Balancer.MaxConcurrentConnections < 0 {
+		return fmt.Errorf("invalid max concurrent connections: %d", cfg.Balancer.MaxConcurrentConnections)
+	}
+
+	switch cfg.Balancer.OnOverflow {
+	case OverflowReject, OverflowWait:
+	default:
+		return fmt.Errorf("unknown on_overflow behavior: %q", cfg.Balancer.OnOverflow)
+	}
+
+	if cfg.Balancer.MinHealthyBackends < 0 {
+		return fmt.Errorf("invalid min healthy backends: %d", cfg.Balancer.MinHealthyBackends)
+	}
+
+	switch cfg.Balancer.NoBackendResponse {
+	case NoBackendReset, NoBackendHTTP503:
+	default:
+		return fmt.Errorf("unknown no_backend_response: %q", cfg.Balancer.NoBackendResponse)
 	}
 
 	if len(cfg.Backends) == 0 {
 		return fmt.Errorf("no backends configured")
 	}
 
+	// Each host:port may appear only once; listing a backend twice would
+	// otherwise double its replicas on the hash ring
+	seen := make(map[string]int, len(cfg.Backends))
 	for i, backend := range cfg.Backends {
+		addr := backend.Addr()
+		if first, ok := seen[addr]; ok {
+			return fmt.Errorf("backend %d: duplicate of backend %d (%s)", i, first, addr)
+		}
+		seen[addr] = i
+
 		if backend.Host == "" {
 			return fmt.Errorf("backend %d: missing host", i)
 		}
-		if backend.Port <= 0 {
+		if netutil.IsUnix(backend.Host) {
+			if backend.Host == netutil.UnixPrefix {
+				return fmt.Errorf("backend %d: missing socket path", i)
+			}
+		} else if backend.Port <= 0 {
 			return fmt.Errorf("backend %d: invalid port: %d", i, backend.Port)
 		}
-		if backend.Weight <= 0 {
+		if backend.Weight < 0 {
 			return fmt.Errorf("backend %d: invalid weight: %d", i, backend.Weight)
 		}
+		if (backend.TLS.CertFile == "") != (backend.TLS.KeyFile == "") {
+			return fmt.Errorf("backend %d: tls cert_file and key_file must be set together", i)
+		}
 	}
 
 	if cfg.Pool.MaxIdle <= 0 {
@@ -97,5 +405,52 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("invalid idle timeout: %v", cfg.Pool.IdleTimeout)
 	}
 
+	if cfg.SlowStart.Duration < 0 {
+		return fmt.Errorf("invalid slow start duration: %v", cfg.SlowStart.Duration)
+	}
+
+	if cfg.KeepAlive.Period < 0 {
+		return fmt.Errorf("invalid keep-alive period: %v", cfg.KeepAlive.Period)
+	}
+
+	if size := cfg.Proxy.BufferSize; size != 0 && (size < MinProxyBufferSize || size > MaxProxyBufferSize) {
+		return fmt.Errorf("invalid proxy buffer size: %d (must be between %d and %d)", size, MinProxyBufferSize, MaxProxyBufferSize)
+	}
+
+	if cfg.Health.MaxConcurrentChecks < 0 {
+		return fmt.Errorf("invalid max concurrent checks: %d", cfg.Health.MaxConcurrentChecks)
+	}
+
+	if cfg.Retry.BufferBytes < 0 {
+		return fmt.Errorf("invalid retry buffer bytes: %d", cfg.Retry.BufferBytes)
+	}
+
+	if cfg.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("invalid retry max attempts: %d", cfg.Retry.MaxAttempts)
+	}
+
+	if cfg.Tracing.Enabled && cfg.Tracing.OTLPEndpoint == "" {
+		return fmt.Errorf("tracing enabled without an OTLP endpoint")
+	}
+
+	return nil
+}
+
+// validateListen checks the balancer listen address
+func validateListen(listen string) error {
+	if netutil.IsUnix(listen) {
+		if listen == netutil.UnixPrefix {
+			return fmt.Errorf("invalid listen address %q: missing socket path", listen)
+		}
+		return nil
+	}
+
+	_, portStr, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	if port, err := strconv.Atoi(portStr); err != nil || port <= 0 {
+		return fmt.Errorf("invalid port: %s", portStr)
+	}
 	return nil
 }
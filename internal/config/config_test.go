@@ -0,0 +1,117 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackendWeight(t *testing.T) {
+	tests := []struct {
+		weight  string
+		wantErr bool
+	}{
+		{"0", false}, // drained, registered without traffic
+		{"3", false},
+		{"-1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.weight, func(t *testing.T) {
+			doc := strings.Replace(baseYAML, "weight: 1", "weight: "+tt.weight, 1)
+			_, err := Load(writeConfig(t, "lb.yaml", doc))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("weight %s: error = %v, want error %t", tt.weight, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProxyBufferSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		wantErr bool
+	}{
+		{"0", false}, // default copy path
+		{"4096", false},
+		{"262144", false},
+		{"16777216", false},
+		{"4095", true},
+		{"16777217", true},
+		{"-1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			doc := baseYAML + "proxy:\n  buffer_size: " + tt.size + "\n"
+			_, err := Load(writeConfig(t, "lb.yaml", doc))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buffer_size %s: error = %v, want error %t", tt.size, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		balancer  string // extra balancer fields
+		health    string
+		wantPhi   float64
+		wantCount int
+		wantErr   string
+	}{
+		{name: "defaults", wantPhi: DefaultPhiThreshold, wantCount: DefaultConsecutiveFailures},
+		{name: "set", health: "{phi_threshold: 4.5, consecutive_failures: 5}", wantPhi: 4.5, wantCount: 5},
+		{name: "deprecated", balancer: "  failure_threshold: 6\n", wantPhi: 6, wantCount: DefaultConsecutiveFailures},
+		{name: "new wins", balancer: "  failure_threshold: 6\n", health: "{phi_threshold: 2}", wantPhi: 2, wantCount: DefaultConsecutiveFailures},
+		{name: "negative phi", health: "{phi_threshold: -1}", wantErr: "invalid phi threshold"},
+		{name: "negative count", health: "{consecutive_failures: -2}", wantErr: "invalid consecutive failures"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := strings.Replace(baseYAML, "  health_check_interval: 1s\n", "  health_check_interval: 1s\n"+tt.balancer, 1)
+			if tt.health != "" {
+				doc += "health: " + tt.health + "\n"
+			}
+			cfg, err := Load(writeConfig(t, "lb.yaml", doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Health.PhiThreshold != tt.wantPhi || cfg.Health.ConsecutiveFailures != tt.wantCount {
+				t.Errorf("phi_threshold %v, consecutive_failures %d; want %v, %d",
+					cfg.Health.PhiThreshold, cfg.Health.ConsecutiveFailures, tt.wantPhi, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestDuplicateBackends(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends string
+		wantErr  bool
+	}{
+		{"distinct ports", "  - {host: 127.0.0.1, port: 9002, weight: 1}\n", false},
+		{"same address", "  - {host: 127.0.0.1, port: 9001, weight: 3}\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := strings.Replace(baseYAML, "  - {host: 127.0.0.1, port: 9001, weight: 1}\n",
+				"  - {host: 127.0.0.1, port: 9001, weight: 1}\n"+tt.backends, 1)
+			_, err := Load(writeConfig(t, "lb.yaml", doc))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "backend 1: duplicate of backend 0") {
+				t.Fatalf("error = %v, want backend 1 named a duplicate of backend 0", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFormatsRoundTrip(t *testing.T) {
+	want := mustLoad(t, writeConfig(t, "lb.yaml", baseYAML))
+
+	encoders := map[string]func(any) ([]byte, error){
+		"lb.yaml": yaml.Marshal,
+		"lb.json": json.Marshal,
+		"lb.toml": func(v any) ([]byte, error) {
+			var buf bytes.Buffer
+			err := toml.NewEncoder(&buf).Encode(v)
+			return buf.Bytes(), err
+		},
+	}
+	for name, encode := range encoders {
+		t.Run(name, func(t *testing.T) {
+			data, err := encode(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := mustLoad(t, writeConfig(t, name, string(data)))
+
+			// Compare encodings, as decoders differ in whether absent
+			// lists and maps come back nil or empty
+			gotYAML, _ := yaml.Marshal(got)
+			wantYAML, _ := yaml.Marshal(want)
+			if !bytes.Equal(gotYAML, wantYAML) {
+				t.Errorf("round trip through %s changed the config:\n got %s\nwant %s", name, gotYAML, wantYAML)
+			}
+		})
+	}
+}
+
+func TestFormatsDecodeDurations(t *testing.T) {
+	docs := map[string]string{
+		"lb.yml": baseYAML,
+		"lb.json": `{
+			"balancer": {"port": 9000, "health_check_interval": "1s"},
+			"backends": [{"host": "127.0.0.1", "port": 9001, "weight": 1}],
+			"pool": {"max_idle": 4, "max_active": 8, "idle_timeout": "30s"}
+		}`,
+		"lb.toml": `
+[balancer]
+port = 9000
+health_check_interval = "1s"
+
+[[backends]]
+host = "127.0.0.1"
+port = 9001
+weight = 1
+
+[pool]
+max_idle = 4
+max_active = 8
+idle_timeout = "30s"
+`,
+		// Unknown extensions are parsed as YAML
+		"lb.conf": baseYAML,
+	}
+	for name, doc := range docs {
+		t.Run(name, func(t *testing.T) {
+			cfg := mustLoad(t, writeConfig(t, name, doc))
+			if got := time.Duration(cfg.Balancer.HealthCheckInterval); got != time.Second {
+				t.Errorf("health check interval = %v, want 1s", got)
+			}
+			if got := time.Duration(cfg.Pool.IdleTimeout); got != 30*time.Second {
+				t.Errorf("idle timeout = %v, want 30s", got)
+			}
+			if len(cfg.Backends) != 1 || cfg.Backends[0].Host != "127.0.0.1" || cfg.Backends[0].Port != 9001 {
+				t.Errorf("backends = %+v, want 127.0.0.1:9001", cfg.Backends)
+			}
+		})
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m5s"`), &d); err != nil || time.Duration(d) != 65*time.Second {
+		t.Errorf(`"1m5s" decoded to %v, %v`, d, err)
+	}
+	if err := json.Unmarshal([]byte(`1500000000`), &d); err != nil || time.Duration(d) != 1500*time.Millisecond {
+		t.Errorf("1500000000 decoded to %v, %v", d, err)
+	}
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error(`"soon" decoded without error`)
+	}
+	if data, _ := json.Marshal(Duration(90 * time.Second)); string(data) != `"1m30s"` {
+		t.Errorf("90s encoded as %s", data)
+	}
+}
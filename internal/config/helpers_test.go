@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// baseYAML is a minimal valid configuration
+const baseYAML = `
+balancer:
+  port: 9000
+  health_check_interval: 1s
+backends:
+  - {host: 127.0.0.1, port: 9001, weight: 1}
+pool: {max_idle: 4, max_active: 8, idle_timeout: 30s}
+`
+
+// writeConfig writes content to name in a temporary directory and returns
+// its path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// mustLoad loads the configuration at path, failing the test on error
+func mustLoad(t *testing.T, path string) *Config {
+	t.Helper()
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	return cfg
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables that override values from the config file
+const (
+	EnvPort                = "LB_PORT"
+	EnvHealthCheckInterval = "LB_HEALTH_INTERVAL"
+	EnvPhiThreshold        = "LB_PHI_THRESHOLD"
+	EnvStrategy            = "LB_STRATEGY"
+	EnvBackends            = "LB_BACKENDS"
+)
+
+// precedenceNote is appended to validation errors so users know which
+// source a conflicting value may have come from
+const precedenceNote = "values are taken from flags, then environment, then the config file"
+
+// Overrides holds settings given on the command line. Zero values are
+// ignored, so only explicitly set flags take precedence over the
+// environment and config file.
+type Overrides struct {
+	Port                int
+	HealthCheckInterval time.Duration
+	PhiThreshold        float64
+	Strategy            string
+	Backends            string
+}
+
+// applyEnv applies environment variable overrides on top of the parsed file
+func applyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv(EnvPort); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: invalid port %q: %w", EnvPort, v, err)
+		}
+		cfg.Balancer.Port = port
+	}
+
+	if v, ok := os.LookupEnv(EnvHealthCheckInterval); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration %q: %w", EnvHealthCheckInterval, v, err)
+		}
+		cfg.Balancer.HealthCheckInterval = Duration(interval)
+	}
+
+	if v, ok := os.LookupEnv(EnvPhiThreshold); ok {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid threshold %q: %w", EnvPhiThreshold, v, err)
+		}
+		cfg.Health.PhiThreshold = threshold
+	}
+
+	if v, ok := os.LookupEnv(EnvStrategy); ok {
+		cfg.Balancer.Strategy = v
+	}
+
+	if v, ok := os.LookupEnv(EnvBackends); ok {
+		backends, err := ParseBackends(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvBackends, err)
+		}
+		cfg.Backends = backends
+	}
+
+	return nil
+}
+
+// apply applies command line overrides on top of the file and environment
+func (o Overrides) apply(cfg *Config) error {
+	if o.Port != 0 {
+		cfg.Balancer.Port = o.Port
+	}
+	if o.HealthCheckInterval != 0 {
+		cfg.Balancer.HealthCheckInterval = Duration(o.HealthCheckInterval)
+	}
+	if o.PhiThreshold != 0 {
+		cfg.Health.PhiThreshold = o.PhiThreshold
+	}
+	if o.Strategy != "" {
+		cfg.Balancer.Strategy = o.Strategy
+	}
+	if o.Backends != "" {
+		backends, err := ParseBackends(o.Backends)
+		if err != nil {
+			return fmt.Errorf("-backends: %w", err)
+		}
+		cfg.Backends = backends
+	}
+	return nil
+}
+
+// ParseBackends parses a comma-separated list of host:port:weight entries
+func ParseBackends(s string) ([]BackendConfig, error) {
+	var backends []BackendConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// Split off the weight first so IPv6 hosts like [::1]:80:1 work
+		idx := strings.LastIndex(entry, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid backend %q: expected host:port:weight", entry)
+		}
+		weight, err := strconv.Atoi(entry[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend %q: bad weight: %w", entry, err)
+		}
+
+		host, portStr, err := net.SplitHostPort(entry[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend %q: %w", entry, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend %q: bad port: %w", entry, err)
+		}
+
+		backends = append(backends, BackendConfig{
+			Host:   host,
+			Port:   port,
+			Weight: weight,
+		})
+	}
+	return backends, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvOverridesFile(t *testing.T) {
+	t.Setenv(EnvPort, "9100")
+	t.Setenv(EnvHealthCheckInterval, "250ms")
+	t.Setenv(EnvPhiThreshold, "12.5")
+	t.Setenv(EnvStrategy, StrategyWeightedLeastConnections)
+	t.Setenv(EnvBackends, "10.0.0.1:81:2, 10.0.0.2:82:3")
+
+	cfg := mustLoad(t, writeConfig(t, "lb.yaml", baseYAML))
+
+	if cfg.Balancer.Port != 9100 {
+		t.Errorf("port = %d, want 9100 from %s", cfg.Balancer.Port, EnvPort)
+	}
+	if got := time.Duration(cfg.Balancer.HealthCheckInterval); got != 250*time.Millisecond {
+		t.Errorf("health check interval = %v, want 250ms", got)
+	}
+	if cfg.Health.PhiThreshold != 12.5 {
+		t.Errorf("phi threshold = %v, want 12.5", cfg.Health.PhiThreshold)
+	}
+	if cfg.Balancer.Strategy != StrategyWeightedLeastConnections {
+		t.Errorf("strategy = %q, want %q", cfg.Balancer.Strategy, StrategyWeightedLeastConnections)
+	}
+	want := []string{"10.0.0.1:81/2", "10.0.0.2:82/3"}
+	if len(cfg.Backends) != len(want) {
+		t.Fatalf("got %d backends, want %d", len(cfg.Backends), len(want))
+	}
+	for i, bc := range cfg.Backends {
+		if got := fmt.Sprintf("%s:%d/%d", bc.Host, bc.Port, bc.Weight); got != want[i] {
+			t.Errorf("backend %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestFlagsOverrideEnv(t *testing.T) {
+	t.Setenv(EnvPort, "9100")
+	t.Setenv(EnvStrategy, StrategyWeightedLeastConnections)
+
+	cfg, err := LoadWithOverrides(writeConfig(t, "lb.yaml", baseYAML), Overrides{
+		Port:     9200,
+		Strategy: StrategyConsistentHash,
+		Backends: "10.0.0.3:83:1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Balancer.Port != 9200 {
+		t.Errorf("port = %d, want 9200 from the flag", cfg.Balancer.Port)
+	}
+	if cfg.Balancer.Strategy != StrategyConsistentHash {
+		t.Errorf("strategy = %q, want %q from the flag", cfg.Balancer.Strategy, StrategyConsistentHash)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].Host != "10.0.0.3" || cfg.Backends[0].Port != 83 {
+		t.Errorf("backends = %+v, want only 10.0.0.3:83", cfg.Backends)
+	}
+}
+
+func TestEnvOverrideErrors(t *testing.T) {
+	tests := []struct {
+		env, value, want string
+	}{
+		{EnvPort, "http", EnvPort},
+		{EnvHealthCheckInterval, "soon", EnvHealthCheckInterval},
+		{EnvBackends, "10.0.0.1", "expected host:port:weight"},
+		// Valid on its own but rejected with the precedence note
+		{EnvPort, "0", precedenceNote},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			_, err := Load(writeConfig(t, "lb.yaml", baseYAML))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBackendsIPv6(t *testing.T) {
+	backends, err := ParseBackends("[::1]:8080:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backends) != 1 || backends[0].Host != "::1" || backends[0].Port != 8080 || backends[0].Weight != 5 {
+		t.Errorf("parsed %+v, want host ::1 port 8080 weight 5", backends)
+	}
+}
@@ -0,0 +1,34 @@
+package connpool
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+func TestCloseStopsCleanup(t *testing.T) {
+	cfg := config.PoolConfig{MaxIdle: 1, MaxActive: 1, IdleTimeout: config.Duration(time.Minute)}
+	d := &pipeDialer{}
+	before := runtime.NumGoroutine()
+	for range 100 {
+		p, err := New(cfg, d.dial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// A second Close must not panic on the closed channel
+		p.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before+5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after creating and closing 100 pools, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
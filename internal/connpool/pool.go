@@ -9,6 +9,9 @@ import (
 	"github.com/ritikchawla/load-balancer/internal/config"
 )
 
+// DialFunc opens a new connection to a backend address
+type DialFunc func(addr string) (net.Conn, error)
+
 // Pool manages a pool of network connections
 type Pool struct {
 	mu sync.Mutex
@@ -21,7 +24,28 @@ type Pool struct {
 	// Connection management
 	active   int
 	idle     map[string][]*idleConn
-	dialFunc func(addr string) (net.Conn, error)
+	addrs    map[net.Conn]string // address each active connection was taken for
+	dialFunc DialFunc
+
+	// Lifecycle
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Counters
+	dials        uint64
+	reuses       uint64
+	closes       uint64
+	idleTimeouts uint64
+}
+
+// Stats is a point-in-time snapshot of pool usage
+type Stats struct {
+	Idle         map[string]int `json:"idle"`
+	Active       int            `json:"active"`
+	Dials        uint64         `json:"dials"`
+	Reuses       uint64         `json:"reuses"`
+	Closes       uint64         `json:"closes"`
+	IdleTimeouts uint64         `json:"idle_timeouts"`
 }
 
 type idleConn struct {
@@ -29,8 +53,9 @@ type idleConn struct {
 	timeAdded time.Time
 }
 
-// New creates a new connection pool
-func New(cfg config.PoolConfig) (*Pool, error) {
+// New creates a new connection pool that opens connections with dialFunc.
+// A nil dialFunc dials plain TCP.
+func New(cfg config.PoolConfig, dialFunc DialFunc) (*Pool, error) {
 	if cfg.MaxIdle <= 0 || cfg.MaxActive <= 0 {
 		return nil, fmt.Errorf("invalid pool configuration")
 	}
@@ -38,11 +63,17 @@ func New(cfg config.PoolConfig) (*Pool, error) {
 	p := &Pool{
 		maxIdle:     cfg.MaxIdle,
 		maxActive:   cfg.MaxActive,
-		idleTimeout: cfg.IdleTimeout,
+		idleTimeout: time.Duration(cfg.IdleTimeout),
 		idle:        make(map[string][]*idleConn),
-		dialFunc: func(addr string) (net.Conn, error) {
+		addrs:       make(map[net.Conn]string),
+		done:        make(chan struct{}),
+		dialFunc:    dialFunc,
+	}
+
+	if p.dialFunc == nil {
+		p.dialFunc = func(addr string) (net.Conn, error) {
 			return net.DialTimeout("tcp", addr, 5*time.Second)
-		},
+		}
 	}
 
 	// Start cleanup routine
@@ -65,10 +96,14 @@ func (p *Pool) Get(addr string) (net.Conn, error) {
 		// Check if connection is still valid
 		if time.Since(conn.timeAdded) > p.idleTimeout {
 			conn.conn.Close()
+			p.closes++
+			p.idleTimeouts++
 			return p.createConn(addr)
 		}
 
 		p.active++
+		p.reuses++
+		p.addrs[conn.conn] = addr
 		return conn.conn, nil
 	}
 
@@ -85,10 +120,15 @@ func (p *Pool) Put(conn net.Conn) error {
 	}
 
 	p.active--
-	addr := conn.RemoteAddr().String()
+	addr, ok := p.addrs[conn]
+	if !ok {
+		addr = conn.RemoteAddr().String()
+	}
+	delete(p.addrs, conn)
 
 	// If we've hit max idle, close the connection
 	if len(p.idle[addr]) >= p.maxIdle {
+		p.closes++
 		return conn.Close()
 	}
 
@@ -101,8 +141,41 @@ func (p *Pool) Put(conn net.Conn) error {
 	return nil
 }
 
+// Discard closes a connection taken from the pool instead of returning it.
+// Use it for connections whose protocol state makes them unfit for reuse.
+func (p *Pool) Discard(conn net.Conn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active <= 0 {
+		return fmt.Errorf("connection not from pool")
+	}
+
+	p.active--
+	p.closes++
+	delete(p.addrs, conn)
+	return conn.Close()
+}
+
+// Evict closes and discards all idle connections to an address
+func (p *Pool) Evict(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conn := range p.idle[addr] {
+		conn.conn.Close()
+		p.closes++
+	}
+	delete(p.idle, addr)
+}
+
 // Close closes the pool and all its connections
 func (p *Pool) Close() error {
+	// Stop the cleanup routine
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -110,6 +183,7 @@ func (p *Pool) Close() error {
 	for addr, conns := range p.idle {
 		for _, conn := range conns {
 			conn.conn.Close()
+			p.closes++
 		}
 		delete(p.idle, addr)
 	}
@@ -117,18 +191,46 @@ func (p *Pool) Close() error {
 	return nil
 }
 
-// createConn creates a new connection if limits allow
+// Stats returns a snapshot of the pool's counters
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := make(map[string]int, len(p.idle))
+	for addr, conns := range p.idle {
+		idle[addr] = len(conns)
+	}
+
+	return Stats{
+		Idle:         idle,
+		Active:       p.active,
+		Dials:        p.dials,
+		Reuses:       p.reuses,
+		Closes:       p.closes,
+		IdleTimeouts: p.idleTimeouts,
+	}
+}
+
+// createConn creates a new connection if limits allow. p.mu must be held;
+// it is released while dialing, so a slow dial or TLS handshake doesn't
+// block other users of the pool, with the active slot reserved meanwhile.
 func (p *Pool) createConn(addr string) (net.Conn, error) {
 	if p.active >= p.maxActive {
 		return nil, fmt.Errorf("max active connections reached")
 	}
+	p.active++
 
+	p.mu.Unlock()
 	conn, err := p.dialFunc(addr)
+	p.mu.Lock()
+
 	if err != nil {
+		p.active--
 		return nil, fmt.Errorf("error dialing connection: %w", err)
 	}
 
-	p.active++
+	p.dials++
+	p.addrs[conn] = addr
 	return conn, nil
 }
 
@@ -137,13 +239,21 @@ func (p *Pool) cleanup() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
 		p.mu.Lock()
 		for addr, conns := range p.idle {
 			valid := make([]*idleConn, 0, len(conns))
 			for _, conn := range conns {
 				if time.Since(conn.timeAdded) > p.idleTimeout {
 					conn.conn.Close()
+					p.closes++
+					p.idleTimeouts++
 					continue
 				}
 				valid = append(valid, conn)
@@ -0,0 +1,174 @@
+package connpool
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// pipeDialer dials in-memory connections and counts the dials per address
+type pipeDialer struct {
+	mu    sync.Mutex
+	dials map[string]int
+}
+
+func (d *pipeDialer) dial(addr string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dials == nil {
+		d.dials = make(map[string]int)
+	}
+	d.dials[addr]++
+	client, server := net.Pipe()
+	go func() {
+		// Hold the server end open until the client closes
+		var buf [1]byte
+		server.Read(buf[:])
+		server.Close()
+	}()
+	return client, nil
+}
+
+// newTestPool returns a pool dialing in-memory connections, closed when the
+// test ends
+func newTestPool(t *testing.T, cfg config.PoolConfig) (*Pool, *pipeDialer) {
+	t.Helper()
+	d := &pipeDialer{}
+	p, err := New(cfg, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, d
+}
+
+func mustGet(t *testing.T, p *Pool, addr string) net.Conn {
+	t.Helper()
+	conn, err := p.Get(addr)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", addr, err)
+	}
+	return conn
+}
+
+func TestStatsCounters(t *testing.T) {
+	const a, b = "10.0.0.1:80", "10.0.0.2:80"
+	p, _ := newTestPool(t, config.PoolConfig{MaxIdle: 1, MaxActive: 4, IdleTimeout: config.Duration(time.Minute)})
+
+	a1 := mustGet(t, p, a)
+	a2 := mustGet(t, p, a)
+	b1 := mustGet(t, p, b)
+	p.Put(a1) // idle
+	p.Put(a2) // over max idle, closed
+	a3 := mustGet(t, p, a)
+	if a3 != a1 {
+		t.Error("Get dialed instead of reusing the idle connection")
+	}
+
+	got := p.Stats()
+	want := Stats{Active: 2, Dials: 3, Reuses: 1, Closes: 1}
+	if got.Active != want.Active || got.Dials != want.Dials || got.Reuses != want.Reuses ||
+		got.Closes != want.Closes || got.IdleTimeouts != 0 {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	if got.Idle[a] != 0 || got.Idle[b] != 0 {
+		t.Errorf("idle = %v, want none", got.Idle)
+	}
+
+	p.Put(a3)
+	p.Put(b1)
+	if got := p.Stats(); got.Idle[a] != 1 || got.Idle[b] != 1 || got.Active != 0 {
+		t.Errorf("after Put: idle %v, active %d; want one idle to each and none active", got.Idle, got.Active)
+	}
+}
+
+func TestStatsIdleTimeouts(t *testing.T) {
+	const a = "10.0.0.1:80"
+	p, d := newTestPool(t, config.PoolConfig{MaxIdle: 2, MaxActive: 4, IdleTimeout: config.Duration(10 * time.Millisecond)})
+
+	p.Put(mustGet(t, p, a))
+	time.Sleep(30 * time.Millisecond)
+	p.Put(mustGet(t, p, a))
+
+	got := p.Stats()
+	if got.IdleTimeouts != 1 || got.Dials != 2 || got.Reuses != 0 {
+		t.Errorf("stats = %+v, want 1 idle timeout, 2 dials and no reuse", got)
+	}
+	if d.dials[a] != 2 {
+		t.Errorf("dialed %s %d times, want 2", a, d.dials[a])
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	const a, b = "10.0.0.1:80", "10.0.0.2:80"
+	p, _ := newTestPool(t, config.PoolConfig{MaxIdle: 1, MaxActive: 1, IdleTimeout: config.Duration(time.Minute)})
+	conn := mustGet(t, p, a)
+	if _, err := p.Get(b); err == nil {
+		t.Fatal("Get beyond max active succeeded")
+	}
+	p.Put(conn)
+}
+
+func TestDialOutsideLock(t *testing.T) {
+	release := make(chan struct{})
+	dialing := make(chan struct{})
+	d := &pipeDialer{}
+	p, err := New(config.PoolConfig{MaxIdle: 1, MaxActive: 2, IdleTimeout: config.Duration(time.Minute)},
+		func(addr string) (net.Conn, error) {
+			if addr == "slow:1" {
+				close(dialing)
+				<-release
+				return nil, errors.New("handshake failed")
+			}
+			return d.dial(addr)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := p.Get("slow:1")
+		slow <- err
+	}()
+	<-dialing
+
+	// The pool stays usable while the dial is in progress, with its slot
+	// reserved
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := p.Get("fast:1")
+		if err != nil {
+			t.Errorf("Get with a dial pending: %v", err)
+			return
+		}
+		if _, err := p.Get("fast:1"); err == nil {
+			t.Error("Get beyond max active with a dial pending succeeded")
+		}
+		p.Put(conn)
+		p.Stats()
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pool blocked while a dial was in progress")
+	}
+
+	// A failed dial gives its slot back
+	close(release)
+	if err := <-slow; err == nil {
+		t.Fatal("slow dial succeeded")
+	}
+	if active := p.Stats().Active; active != 0 {
+		t.Errorf("%d active after the dial failed, want 0", active)
+	}
+	a, b := mustGet(t, p, "fast:2"), mustGet(t, p, "fast:3")
+	p.Put(a)
+	p.Put(b)
+}
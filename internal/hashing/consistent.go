@@ -13,53 +13,120 @@ type ConsistentHasher struct {
 	hash    map[uint32]string
 	nodes   []uint32
 	weights map[string]int
+
+	// Replica positions of nodes holding other than their weight's share
+	replicas map[string]int
 }
 
 // New creates a new ConsistentHasher instance
 func New() *ConsistentHasher {
 	return &ConsistentHasher{
-		hash:    make(map[uint32]string),
-		nodes:   make([]uint32, 0),
-		weights: make(map[string]int),
+		hash:     make(map[uint32]string),
+		nodes:    make([]uint32, 0),
+		weights:  make(map[string]int),
+		replicas: make(map[string]int),
 	}
 }
 
-// Add adds a node to the hash ring with optional weight
+// Add adds a node to the hash ring with optional weight. A zero weight
+// registers the node without any replicas. Adding a node again sets its
+// weight rather than duplicating its positions.
 func (c *ConsistentHasher) Add(node string, weight int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.setReplicas(node, weight, max(replicationFactor*weight, 0))
 	c.weights[node] = weight
-	for i := 0; i < replicationFactor*weight; i++ {
-		hash := c.hashKey(node + string(rune(i)))
-		c.hash[hash] = node
-		c.nodes = append(c.nodes, hash)
-	}
-	sort.Slice(c.nodes, func(i, j int) bool {
-		return c.nodes[i] < c.nodes[j]
-	})
 }
 
-// Remove removes a node from the hash ring
+// Remove removes a node from the hash ring, along with exactly the
+// positions it owns
 func (c *ConsistentHasher) Remove(node string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	weight := c.weights[node]
+	c.setReplicas(node, 0, 0)
 	delete(c.weights, node)
+}
+
+// UpdateWeight changes a node's weight by adding or removing only the
+// replica positions needed to reach the new replica count. Keys owned by
+// the node's remaining replicas do not move. Unknown nodes are added.
+func (c *ConsistentHasher) UpdateWeight(node string, weight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setReplicas(node, weight, max(replicationFactor*weight, 0))
+}
+
+// UpdateWeightFraction gives a node the given fraction of the replica
+// positions its weight calls for, rounded down but at least one, so a node
+// can be ramped up more gradually than whole weight units allow. It reports
+// whether the node's positions changed.
+func (c *ConsistentHasher) UpdateWeightFraction(node string, weight int, fraction float64) bool {
+	replicas := max(replicationFactor*weight, 0)
+	if weight > 0 && fraction < 1 {
+		replicas = min(max(int(float64(replicas)*fraction), 1), replicas)
+	}
 
-	for i := 0; i < replicationFactor*weight; i++ {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := replicas != c.replicaCount(node)
+	c.setReplicas(node, weight, replicas)
+	return changed
+}
+
+// replicaCount returns the number of replica positions a node holds. c.mu
+// must be held.
+func (c *ConsistentHasher) replicaCount(node string) int {
+	if n, ok := c.replicas[node]; ok {
+		return n
+	}
+	return replicationFactor * c.weights[node]
+}
+
+// setReplicas gives a node of the given weight exactly replicas positions,
+// adding or removing only the difference. c.mu must be held.
+func (c *ConsistentHasher) setReplicas(node string, weight, replicas int) {
+	oldReplicas := c.replicaCount(node)
+	c.weights[node] = weight
+	if replicas == replicationFactor*weight {
+		delete(c.replicas, node)
+	} else {
+		c.replicas[node] = replicas
+	}
+
+	if replicas > oldReplicas {
+		for i := oldReplicas; i < replicas; i++ {
+			hash := c.hashKey(node + string(rune(i)))
+			c.hash[hash] = node
+			c.nodes = append(c.nodes, hash)
+		}
+		sort.Slice(c.nodes, func(i, j int) bool {
+			return c.nodes[i] < c.nodes[j]
+		})
+		return
+	}
+
+	removed := make(map[uint32]bool)
+	for i := replicas; i < oldReplicas; i++ {
 		hash := c.hashKey(node + string(rune(i)))
-		delete(c.hash, hash)
+		if c.hash[hash] == node {
+			delete(c.hash, hash)
+			removed[hash] = true
+		}
 	}
 
-	nodes := make([]uint32, 0)
+	nodes := make([]uint32, 0, len(c.nodes)-len(removed))
 	for _, v := range c.nodes {
-		if c.hash[v] != node {
+		if !removed[v] {
 			nodes = append(nodes, v)
 		}
 	}
 	c.nodes = nodes
+
+	if weight <= 0 {
+		delete(c.weights, node)
+	}
 }
 
 // Get returns the node that a key hashes to
@@ -83,6 +150,35 @@ func (c *ConsistentHasher) Get(key string) string {
 	return c.hash[c.nodes[idx]]
 }
 
+// GetWhere returns the first node at or after the key's ring position for
+// which accept returns true, or "" if no node is accepted
+func (c *ConsistentHasher) GetWhere(key string, accept func(node string) bool) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.nodes) == 0 {
+		return ""
+	}
+
+	hash := c.hashKey(key)
+	start := sort.Search(len(c.nodes), func(i int) bool {
+		return c.nodes[i] >= hash
+	})
+
+	checked := make(map[string]bool)
+	for i := 0; i < len(c.nodes) && len(checked) < len(c.weights); i++ {
+		node := c.hash[c.nodes[(start+i)%len(c.nodes)]]
+		if checked[node] {
+			continue
+		}
+		if accept(node) {
+			return node
+		}
+		checked[node] = true
+	}
+	return ""
+}
+
 // hashKey generates a hash for a key
 func (c *ConsistentHasher) hashKey(key string) uint32 {
 	return crc32.ChecksumIEEE([]byte(key))
@@ -0,0 +1,149 @@
+package hashing
+
+import (
+	"fmt"
+	"testing"
+)
+
+// owners maps each of n sample keys to the node it hashes to
+func owners(c *ConsistentHasher, n int) map[string]string {
+	m := make(map[string]string, n)
+	for i := range n {
+		key := fmt.Sprintf("client-%d", i)
+		m[key] = c.Get(key)
+	}
+	return m
+}
+
+// positions counts the ring positions held by node
+func positions(c *ConsistentHasher, node string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n := 0
+	for _, hash := range c.nodes {
+		if c.hash[hash] == node {
+			n++
+		}
+	}
+	return n
+}
+
+func TestUpdateWeightMovesOnlyNewRanges(t *testing.T) {
+	c := New()
+	c.Add("a", 1)
+	c.Add("b", 1)
+	c.Add("c", 1)
+	before := owners(c, 10000)
+
+	c.UpdateWeight("b", 3)
+	after := owners(c, 10000)
+
+	moved := 0
+	for key, was := range before {
+		now := after[key]
+		if now == was {
+			continue
+		}
+		// Only ranges taken by b's new replicas may change hands
+		if now != "b" {
+			t.Fatalf("key %s moved from %s to %s, not to the reweighted node", key, was, now)
+		}
+		moved++
+	}
+	if moved == 0 {
+		t.Fatal("raising b's weight moved no keys to it")
+	}
+	if moved > len(before)*3/4 {
+		t.Errorf("%d of %d keys moved, want only the new replicas' share", moved, len(before))
+	}
+	if got := positions(c, "b"); got != 300 {
+		t.Errorf("b has %d positions, want 300", got)
+	}
+
+	// Lowering it back returns exactly the original ring
+	c.UpdateWeight("b", 1)
+	for key, was := range owners(c, 10000) {
+		if before[key] != was {
+			t.Fatalf("key %s owned by %s after restoring the weight, was %s", key, was, before[key])
+		}
+	}
+}
+
+func TestUpdateWeightLowerMovesOnlyOffNode(t *testing.T) {
+	c := New()
+	c.Add("a", 2)
+	c.Add("b", 4)
+	before := owners(c, 10000)
+
+	c.UpdateWeight("b", 1)
+	for key, now := range owners(c, 10000) {
+		if was := before[key]; was != now && was != "b" {
+			t.Fatalf("key %s moved from %s to %s though only b lost replicas", key, was, now)
+		}
+	}
+}
+
+func TestUpdateWeightZeroRemovesNode(t *testing.T) {
+	c := New()
+	c.Add("a", 1)
+	c.Add("b", 1)
+	c.UpdateWeight("b", 0)
+	if positions(c, "b") != 0 {
+		t.Error("node with weight 0 is still on the ring")
+	}
+	for key, node := range owners(c, 100) {
+		if node != "a" {
+			t.Fatalf("key %s routed to %s, want a", key, node)
+		}
+	}
+}
+
+func TestUpdateWeightFraction(t *testing.T) {
+	c := New()
+	c.Add("a", 1)
+	c.Add("b", 1)
+
+	if !c.UpdateWeightFraction("b", 1, 0.1) {
+		t.Fatal("ramping b down reported no change")
+	}
+	if got := positions(c, "b"); got != 10 {
+		t.Errorf("b has %d positions at a tenth of weight 1, want 10", got)
+	}
+	if c.UpdateWeightFraction("b", 1, 0.105) {
+		t.Error("a fraction that keeps the same positions reported a change")
+	}
+	if got := c.UpdateWeightFraction("b", 1, 0.001); !got || positions(c, "b") != 1 {
+		t.Errorf("tiny fraction left b with %d positions, want 1", positions(c, "b"))
+	}
+	c.UpdateWeightFraction("b", 2, 1)
+	if got := positions(c, "b"); got != 200 {
+		t.Errorf("b has %d positions at its full weight of 2, want 200", got)
+	}
+	if !c.UpdateWeightFraction("b", 0, 0.5) {
+		t.Error("removing b reported no change")
+	}
+	if positions(c, "b") != 0 {
+		t.Error("node with weight 0 is still on the ring")
+	}
+}
+
+func TestAddTwiceKeepsReplicas(t *testing.T) {
+	c := New()
+	c.Add("a", 2)
+	c.Add("b", 1)
+	c.Add("a", 2)
+	if got := positions(c, "a"); got != 200 {
+		t.Errorf("a has %d positions after being added twice, want 200", got)
+	}
+	if got := len(c.nodes); got != 300 {
+		t.Errorf("ring has %d points, want 300", got)
+	}
+
+	c.Remove("a")
+	if got := positions(c, "a"); got != 0 {
+		t.Fatalf("removed node a still owns %d positions", got)
+	}
+	if got := c.Get("key"); got != "b" {
+		t.Errorf("key maps to %q after removing a, want b", got)
+	}
+}
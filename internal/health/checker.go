@@ -2,18 +2,20 @@ package health
 
 import (
 	"context"
+	"hash/fnv"
 	"math"
+	"math/rand"
 	"net"
+	"strconv"
 	"sync"
 	"time"
-)
 
-const (
-	sampleSize = 1000
-	// phiThreshold is the minimum value for considering a node as failed
-	defaultPhiThreshold = 8.0
+	"github.com/ritikchawla/load-balancer/internal/config"
+	"github.com/ritikchawla/load-balancer/internal/netutil"
 )
 
+const sampleSize = 1000
+
 // HealthUpdateFunc is called when a backend's health status changes
 type HealthUpdateFunc func(host string, healthy bool)
 
@@ -22,12 +24,32 @@ type Checker struct {
 	mu sync.RWMutex
 
 	// Configuration
-	interval     time.Duration
-	phiThreshold float64
+	interval            time.Duration
+	mode                string
+	phiThreshold        float64
+	consecutiveFailures int
+	jitter              bool
+	jitterSeed          int64
+
+	// probeSlots bounds concurrent probes; nil when unlimited
+	probeSlots chan struct{}
+	dialFunc   func(host string) (net.Conn, error)
 
 	// State tracking
 	histories map[string]*history
 	lastCheck map[string]time.Time
+	failures  map[string]int // consecutive failed probes
+	offsets   map[string]time.Duration
+}
+
+// HealthStats is a snapshot of a backend's failure detector state
+type HealthStats struct {
+	Phi         float64         `json:"phi"`
+	Mean        config.Duration `json:"mean"`
+	StdDev      config.Duration `json:"std_dev"`
+	SampleCount int             `json:"sample_count"`
+	LastCheck   time.Time       `json:"last_check"`
+	Healthy     bool            `json:"healthy"`
 }
 
 // history tracks the health check timing history for a backend
@@ -41,17 +63,78 @@ type history struct {
 }
 
 // New creates a new health checker
-func New(interval time.Duration, phiThreshold float64) *Checker {
-	if phiThreshold <= 0 {
-		phiThreshold = defaultPhiThreshold
+func New(interval time.Duration, cfg config.HealthConfig) *Checker {
+	if cfg.PhiThreshold <= 0 {
+		cfg.PhiThreshold = config.DefaultPhiThreshold
+	}
+	if cfg.ConsecutiveFailures <= 0 {
+		cfg.ConsecutiveFailures = config.DefaultConsecutiveFailures
+	}
+
+	c := &Checker{
+		interval:            interval,
+		mode:                cfg.Mode,
+		phiThreshold:        cfg.PhiThreshold,
+		consecutiveFailures: cfg.ConsecutiveFailures,
+		jitter:              cfg.Jitter,
+		jitterSeed:          cfg.JitterSeed,
+		histories:           make(map[string]*history),
+		lastCheck:           make(map[string]time.Time),
+		failures:            make(map[string]int),
+		offsets:             make(map[string]time.Duration),
+		dialFunc: func(host string) (net.Conn, error) {
+			return netutil.DialTimeout(host, 5*time.Second)
+		},
+	}
+
+	if cfg.MaxConcurrentChecks > 0 {
+		c.probeSlots = make(chan struct{}, cfg.MaxConcurrentChecks)
+	}
+
+	return c
+}
+
+// Add registers a backend address to be probed
+func (c *Checker) Add(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.histories[host]; !exists {
+		c.histories[host] = &history{
+			times: make([]time.Duration, sampleSize),
+		}
+		c.offsets[host] = c.jitterOffset(host)
+	}
+}
+
+// jitterOffset returns the fixed delay within each interval at which a host
+// is probed, so probes spread out instead of firing in lockstep. The offset
+// stays the same every round to keep heartbeat intervals regular. With a
+// seed configured the offset is derived from the host for reproducibility.
+func (c *Checker) jitterOffset(host string) time.Duration {
+	if !c.jitter || c.interval <= 0 {
+		return 0
 	}
 
-	return &Checker{
-		interval:     interval,
-		phiThreshold: phiThreshold,
-		histories:    make(map[string]*history),
-		lastCheck:    make(map[string]time.Time),
+	if c.jitterSeed == 0 {
+		return time.Duration(rand.Int63n(int64(c.interval)))
 	}
+
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(c.jitterSeed, 10)))
+	h.Write([]byte(host))
+	return time.Duration(h.Sum64() % uint64(c.interval))
+}
+
+// Remove stops probing a backend address and discards its history
+func (c *Checker) Remove(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.histories, host)
+	delete(c.lastCheck, host)
+	delete(c.failures, host)
+	delete(c.offsets, host)
 }
 
 // Start begins the health checking process
@@ -64,61 +147,88 @@ func (c *Checker) Start(ctx context.Context, updateFunc HealthUpdateFunc) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			c.checkAll(updateFunc)
+			c.checkAll(ctx, updateFunc)
 		}
 	}
 }
 
-// checkAll performs health checks on all backends
-func (c *Checker) checkAll(updateFunc HealthUpdateFunc) {
+// checkAll performs health checks on all backends, each delayed by its
+// jitter offset and bounded by the probe concurrency limit
+func (c *Checker) checkAll(ctx context.Context, updateFunc HealthUpdateFunc) {
 	c.mu.RLock()
-	hosts := make([]string, 0, len(c.histories))
+	offsets := make(map[string]time.Duration, len(c.histories))
 	for host := range c.histories {
-		hosts = append(hosts, host)
+		offsets[host] = c.offsets[host]
 	}
 	c.mu.RUnlock()
 
-	for _, host := range hosts {
-		go func(host string) {
-			healthy := c.check(host)
-			updateFunc(host, healthy)
-		}(host)
+	for host, offset := range offsets {
+		go func(host string, offset time.Duration) {
+			if offset > 0 {
+				timer := time.NewTimer(offset)
+				defer timer.Stop()
+				select {
+				case <-ctx.Done():
+					return
+				case <-timer.C:
+				}
+			}
+
+			if c.probeSlots != nil {
+				select {
+				case c.probeSlots <- struct{}{}:
+					defer func() { <-c.probeSlots }()
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			c.check(host)
+			updateFunc(host, c.IsHealthy(host))
+		}(host, offset)
 	}
 }
 
-// check performs a health check on a single backend
-func (c *Checker) check(host string) bool {
-	start := time.Now()
-
+// check performs a health check on a single backend and records the result
+func (c *Checker) check(host string) {
 	// Attempt connection
-	conn, err := net.DialTimeout("tcp", host, 5*time.Second)
+	conn, err := c.dialFunc(host)
 	if err != nil {
 		c.recordFailure(host)
-		return false
+		return
 	}
 	conn.Close()
 
-	// Record successful check
-	c.recordSuccess(host, time.Since(start))
-	return true
+	// Record successful check as a heartbeat
+	c.recordSuccess(host, time.Now())
 }
 
-// recordSuccess updates timing history for successful health checks
-func (c *Checker) recordSuccess(host string, duration time.Duration) {
+// recordSuccess records a heartbeat and updates the inter-arrival history
+func (c *Checker) recordSuccess(host string, now time.Time) {
 	c.mu.Lock()
-	if _, exists := c.histories[host]; !exists {
-		c.histories[host] = &history{
+	hist, exists := c.histories[host]
+	if !exists {
+		hist = &history{
 			times: make([]time.Duration, sampleSize),
 		}
+		c.histories[host] = hist
 	}
-	c.lastCheck[host] = time.Now()
+	last, seen := c.lastCheck[host]
+	failed := c.failures[host] > 0
+	c.lastCheck[host] = now
+	delete(c.failures, host)
 	c.mu.Unlock()
 
-	hist := c.histories[host]
+	// The first heartbeat has no interval to record, and the first after
+	// failed probes would record the whole outage as a single interval
+	if !seen || failed {
+		return
+	}
+
 	hist.mu.Lock()
 	defer hist.mu.Unlock()
 
-	hist.times[hist.index] = duration
+	hist.times[hist.index] = now.Sub(last)
 	hist.index = (hist.index + 1) % sampleSize
 	if hist.count < sampleSize {
 		hist.count++
@@ -128,10 +238,11 @@ func (c *Checker) recordSuccess(host string, duration time.Duration) {
 	hist.updateStats()
 }
 
-// recordFailure records a failed health check
+// recordFailure records a failed health check. The last heartbeat is left
+// untouched so that phi keeps growing while probes fail.
 func (c *Checker) recordFailure(host string) {
 	c.mu.Lock()
-	c.lastCheck[host] = time.Now()
+	c.failures[host]++
 	c.mu.Unlock()
 }
 
@@ -158,15 +269,26 @@ func (h *history) updateStats() {
 
 // phi calculates the phi value for failure detection
 func (c *Checker) phi(host string) float64 {
+	return c.phiAt(host, time.Now())
+}
+
+// phiAt calculates the phi value as observed at the given time
+func (c *Checker) phiAt(host string, now time.Time) float64 {
 	c.mu.RLock()
 	lastTime, ok := c.lastCheck[host]
+	failed := c.failures[host] > 0
+	hist := c.histories[host]
+	c.mu.RUnlock()
+
 	if !ok {
-		c.mu.RUnlock()
+		// A backend that has never answered a probe but has failed one
+		// is considered down rather than unknown
+		if failed {
+			return math.Inf(1)
+		}
 		return 0.0
 	}
-	c.mu.RUnlock()
 
-	hist := c.histories[host]
 	if hist == nil {
 		return 0.0
 	}
@@ -174,16 +296,24 @@ func (c *Checker) phi(host string) float64 {
 	hist.mu.RLock()
 	defer hist.mu.RUnlock()
 
+	// Without a heartbeat interval to compare against, failed probes are
+	// all there is to go on
 	if hist.count == 0 {
+		if failed {
+			return math.Inf(1)
+		}
 		return 0.0
 	}
 
-	timeSinceLastCheck := time.Since(lastTime)
+	timeSinceLastCheck := now.Sub(lastTime)
 	stdDev := float64(hist.stdDev)
 	mean := float64(hist.mean)
 
 	if stdDev == 0 {
-		stdDev = float64(hist.mean) / 10
+		stdDev = mean / 10
+	}
+	if stdDev == 0 {
+		return 0.0
 	}
 
 	y := (float64(timeSinceLastCheck) - mean) / stdDev
@@ -195,7 +325,56 @@ func normalCDF(x float64) float64 {
 	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
 }
 
-// IsHealthy returns whether a backend is considered healthy
+// Snapshot returns the current failure detector state of every backend
+func (c *Checker) Snapshot() map[string]HealthStats {
+	c.mu.RLock()
+	hosts := make([]string, 0, len(c.histories))
+	for host := range c.histories {
+		hosts = append(hosts, host)
+	}
+	c.mu.RUnlock()
+
+	now := time.Now()
+	snapshot := make(map[string]HealthStats, len(hosts))
+	for _, host := range hosts {
+		c.mu.RLock()
+		hist := c.histories[host]
+		lastCheck := c.lastCheck[host]
+		c.mu.RUnlock()
+		if hist == nil {
+			continue
+		}
+
+		stats := HealthStats{
+			Phi:       c.phiAt(host, now),
+			LastCheck: lastCheck,
+			Healthy:   c.IsHealthy(host),
+		}
+		// JSON can't encode infinity
+		if math.IsInf(stats.Phi, 1) {
+			stats.Phi = math.MaxFloat64
+		}
+
+		hist.mu.RLock()
+		stats.Mean = config.Duration(hist.mean)
+		stats.StdDev = config.Duration(hist.stdDev)
+		stats.SampleCount = hist.count
+		hist.mu.RUnlock()
+
+		snapshot[host] = stats
+	}
+	return snapshot
+}
+
+// IsHealthy returns whether a backend is considered healthy. In tcp mode a
+// backend is healthy until it fails the configured number of consecutive
+// probes; otherwise its phi must stay below the phi threshold.
 func (c *Checker) IsHealthy(host string) bool {
+	if c.mode == config.HealthModeTCP {
+		c.mu.RLock()
+		failures := c.failures[host]
+		c.mu.RUnlock()
+		return failures < c.consecutiveFailures
+	}
 	return c.phi(host) < c.phiThreshold
 }
@@ -0,0 +1,265 @@
+package health
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// heartbeats records n successful probes of host every interval, the last
+// one at end
+func heartbeats(c *Checker, host string, n int, interval time.Duration, end time.Time) {
+	for i := n - 1; i >= 0; i-- {
+		c.recordSuccess(host, end.Add(-time.Duration(i)*interval))
+	}
+}
+
+// samples returns the number and mean of the host's recorded intervals
+func samples(c *Checker, host string) (int, time.Duration) {
+	c.mu.RLock()
+	hist := c.histories[host]
+	c.mu.RUnlock()
+	hist.mu.RLock()
+	defer hist.mu.RUnlock()
+	return hist.count, hist.mean
+}
+
+func TestPhiRisesWithGap(t *testing.T) {
+	c := New(time.Second, config.HealthConfig{})
+	const host = "127.0.0.1:9001"
+	last := time.Now()
+	heartbeats(c, host, 20, 100*time.Millisecond, last)
+
+	prev := -1.0
+	for _, gap := range []time.Duration{50, 100, 120, 150, 200, 400} {
+		phi := c.phiAt(host, last.Add(gap*time.Millisecond))
+		if phi < prev {
+			t.Fatalf("phi after %dms = %v, below %v for a shorter gap", gap, phi, prev)
+		}
+		prev = phi
+	}
+	if phi := c.phiAt(host, last.Add(50*time.Millisecond)); phi >= config.DefaultPhiThreshold {
+		t.Errorf("phi within the usual interval = %v, want below %v", phi, config.DefaultPhiThreshold)
+	}
+	if phi := c.phiAt(host, last.Add(400*time.Millisecond)); phi < config.DefaultPhiThreshold {
+		t.Errorf("phi after four missed intervals = %v, want at least %v", phi, config.DefaultPhiThreshold)
+	}
+}
+
+func TestPhiFlipsUnhealthyAndRecovers(t *testing.T) {
+	c := New(time.Second, config.HealthConfig{})
+	const host = "127.0.0.1:9001"
+
+	// Regular heartbeats ending just now
+	heartbeats(c, host, 20, 100*time.Millisecond, time.Now())
+	if !c.IsHealthy(host) {
+		t.Fatal("backend with regular heartbeats is unhealthy")
+	}
+
+	// The same heartbeats ending a second ago, then failed probes
+	c = New(time.Second, config.HealthConfig{})
+	heartbeats(c, host, 20, 100*time.Millisecond, time.Now().Add(-time.Second))
+	for range 3 {
+		c.recordFailure(host)
+	}
+	if c.IsHealthy(host) {
+		t.Fatalf("backend silent for ten intervals is healthy, phi %v", c.phi(host))
+	}
+
+	count, mean := samples(c, host)
+	c.recordSuccess(host, time.Now())
+	if !c.IsHealthy(host) {
+		t.Fatalf("backend is unhealthy after probes resumed, phi %v", c.phi(host))
+	}
+
+	// The outage is not a heartbeat interval
+	if gotCount, gotMean := samples(c, host); gotCount != count || gotMean != mean {
+		t.Errorf("success after failures recorded a sample: mean %v over %d samples, was %v over %d",
+			gotMean, gotCount, mean, count)
+	}
+}
+
+func TestCheckerReportsPhiVerdict(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	go acceptAll(ln)
+
+	c := New(20*time.Millisecond, config.HealthConfig{})
+	c.Add(addr)
+	updates := make(chan bool, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx, func(host string, healthy bool) {
+		if host == addr {
+			select {
+			case updates <- healthy:
+			default:
+			}
+		}
+	})
+
+	awaitHealth(t, updates, true)
+	ln.Close()
+	awaitHealth(t, updates, false)
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("can't listen on %s again: %v", addr, err)
+	}
+	defer ln.Close()
+	go acceptAll(ln)
+	awaitHealth(t, updates, true)
+}
+
+// acceptAll accepts and closes connections until ln is closed
+func acceptAll(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
+
+// awaitHealth waits for a health update reporting want
+func awaitHealth(t *testing.T, updates <-chan bool, want bool) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case healthy := <-updates:
+			if healthy == want {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no update reporting healthy=%t", want)
+		}
+	}
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	c := New(time.Second, config.HealthConfig{Mode: config.HealthModeTCP, ConsecutiveFailures: 3})
+	const host = "127.0.0.1:9001"
+	c.Add(host)
+	c.recordSuccess(host, time.Now())
+
+	for i := 1; i <= 3; i++ {
+		c.recordFailure(host)
+		if healthy, want := c.IsHealthy(host), i < 3; healthy != want {
+			t.Fatalf("after %d failures healthy = %t, want %t", i, healthy, want)
+		}
+	}
+
+	// A pass resets the count; phi plays no part in tcp mode
+	c = New(time.Second, config.HealthConfig{Mode: config.HealthModeTCP, ConsecutiveFailures: 3})
+	c.Add(host)
+	heartbeats(c, host, 20, 100*time.Millisecond, time.Now().Add(-time.Minute))
+	c.recordFailure(host)
+	c.recordFailure(host)
+	c.recordSuccess(host, time.Now().Add(-time.Minute))
+	c.recordFailure(host)
+	c.recordFailure(host)
+	if !c.IsHealthy(host) {
+		t.Errorf("unhealthy after two failures since the last pass, phi %v", c.phi(host))
+	}
+}
+
+func TestPhiThreshold(t *testing.T) {
+	const host = "127.0.0.1:9001"
+	// Half an interval late, phi is about 6.5
+	last := time.Now().Add(-150 * time.Millisecond)
+	for _, tt := range []struct {
+		threshold float64
+		want      bool
+	}{
+		{1, false},
+		{30, true},
+	} {
+		c := New(time.Second, config.HealthConfig{PhiThreshold: tt.threshold, ConsecutiveFailures: 1})
+		c.Add(host)
+		heartbeats(c, host, 20, 100*time.Millisecond, last)
+		if got := c.IsHealthy(host); got != tt.want {
+			t.Errorf("phi %v, threshold %v: healthy = %t, want %t", c.phi(host), tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotReflectsTimings(t *testing.T) {
+	c := New(time.Second, config.HealthConfig{})
+	const host = "127.0.0.1:9001"
+	c.Add(host)
+
+	// Heartbeats alternately 80ms and 120ms apart, the last a second ago
+	at := time.Now().Add(-2 * time.Second)
+	c.recordSuccess(host, at)
+	for i := range 10 {
+		gap := 80 * time.Millisecond
+		if i%2 == 1 {
+			gap = 120 * time.Millisecond
+		}
+		at = at.Add(gap)
+		c.recordSuccess(host, at)
+	}
+
+	stats, ok := c.Snapshot()[host]
+	if !ok {
+		t.Fatalf("snapshot lacks %s", host)
+	}
+	if stats.SampleCount != 10 {
+		t.Errorf("sample count %d, want 10", stats.SampleCount)
+	}
+	if mean := time.Duration(stats.Mean); mean != 100*time.Millisecond {
+		t.Errorf("mean %v, want 100ms", mean)
+	}
+	if sd := time.Duration(stats.StdDev); sd < 19*time.Millisecond || sd > 21*time.Millisecond {
+		t.Errorf("std dev %v, want 20ms", sd)
+	}
+	if !stats.LastCheck.Equal(at) {
+		t.Errorf("last check %v, want %v", stats.LastCheck, at)
+	}
+	if stats.Phi < config.DefaultPhiThreshold {
+		t.Errorf("phi %v, want the phi of a backend silent for over a second", stats.Phi)
+	}
+	if stats.Healthy {
+		t.Error("backend silent for ten intervals reported healthy")
+	}
+}
+
+func TestSnapshotAfterProbeCycles(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAll(ln)
+	addr := ln.Addr().String()
+
+	c := New(20*time.Millisecond, config.HealthConfig{})
+	c.Add(addr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx, func(string, bool) {})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Snapshot()[addr].SampleCount < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot after 5s: %+v", c.Snapshot()[addr])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := c.Snapshot()[addr]
+	if mean := time.Duration(stats.Mean); mean < 10*time.Millisecond || mean > 100*time.Millisecond {
+		t.Errorf("mean %v for probes every 20ms", mean)
+	}
+	if !stats.Healthy || stats.LastCheck.IsZero() {
+		t.Errorf("snapshot %+v, want a healthy backend with a last check", stats)
+	}
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+// slowDialer holds each probe connection open for a while and records the
+// most dials seen in progress at once
+type slowDialer struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	running int
+	peak    int
+	dials   int
+}
+
+func (d *slowDialer) dial(host string) (net.Conn, error) {
+	d.mu.Lock()
+	d.running++
+	d.dials++
+	d.peak = max(d.peak, d.running)
+	d.mu.Unlock()
+
+	time.Sleep(d.delay)
+
+	d.mu.Lock()
+	d.running--
+	d.mu.Unlock()
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func (d *slowDialer) stats() (peak, dials int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.peak, d.dials
+}
+
+func TestMaxConcurrentChecks(t *testing.T) {
+	const backends, limit = 12, 3
+	d := &slowDialer{delay: 20 * time.Millisecond}
+	c := New(10*time.Millisecond, config.HealthConfig{MaxConcurrentChecks: limit})
+	c.dialFunc = d.dial
+	for i := range backends {
+		c.Add(fmt.Sprintf("10.0.0.%d:80", i+1))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx, func(string, bool) {})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, dials := d.stats(); dials >= 4*backends {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("probes did not run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	if peak, _ := d.stats(); peak > limit {
+		t.Errorf("%d probes ran at once, want at most %d", peak, limit)
+	} else if peak < limit {
+		t.Logf("only %d probes overlapped", peak)
+	}
+}
+
+func TestJitterOffset(t *testing.T) {
+	const interval = time.Second
+	hosts := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.4:80"}
+
+	seeded := func(seed int64) *Checker {
+		return New(interval, config.HealthConfig{Jitter: true, JitterSeed: seed})
+	}
+	a, b := seeded(42), seeded(42)
+	distinct := make(map[time.Duration]bool)
+	for _, host := range hosts {
+		offset := a.jitterOffset(host)
+		if offset < 0 || offset >= interval {
+			t.Errorf("offset of %s = %v, want within [0, %v)", host, offset, interval)
+		}
+		if again := b.jitterOffset(host); again != offset {
+			t.Errorf("offset of %s = %v with the same seed, was %v", host, again, offset)
+		}
+		distinct[offset] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("all hosts got the same offset %v; want probes spread out", a.jitterOffset(hosts[0]))
+	}
+
+	differ := false
+	for _, host := range hosts {
+		differ = differ || seeded(7).jitterOffset(host) != a.jitterOffset(host)
+	}
+	if !differ {
+		t.Error("another seed gave every host the same offsets")
+	}
+
+	if off := New(interval, config.HealthConfig{}).jitterOffset(hosts[0]); off != 0 {
+		t.Errorf("offset without jitter = %v, want 0", off)
+	}
+}
@@ -0,0 +1,80 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// UnixPrefix marks an address as a Unix domain socket path, e.g.
+// "unix:/run/app.sock"
+const UnixPrefix = "unix:"
+
+// IsUnix reports whether addr names a Unix domain socket
+func IsUnix(addr string) bool {
+	return strings.HasPrefix(addr, UnixPrefix)
+}
+
+// SplitNetwork returns the network and address to dial or listen on for
+// addr, which is either host:port or unix:/path
+func SplitNetwork(addr string) (network, address string) {
+	if IsUnix(addr) {
+		return "unix", strings.TrimPrefix(addr, UnixPrefix)
+	}
+	return "tcp", addr
+}
+
+// JoinHostPort builds an address from a host and port. Unix socket hosts
+// are addresses on their own and the port is ignored.
+func JoinHostPort(host string, port int) string {
+	if IsUnix(host) {
+		return host
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// DialTimeout connects to addr over TCP or a Unix socket
+func DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	network, address := SplitNetwork(addr)
+	return net.DialTimeout(network, address, timeout)
+}
+
+// Listen listens on addr over TCP or a Unix socket. A stale socket file
+// left behind by a previous run is removed first; the listener removes the
+// file again when closed.
+func Listen(addr string) (net.Listener, error) {
+	network, address := SplitNetwork(addr)
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// removeStaleSocket deletes a socket file nobody is listening on. Live
+// sockets and other kinds of files are left alone so Listen fails loudly.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking socket %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing stale socket %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package netutil
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitNetwork(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{"127.0.0.1:80", "tcp", "127.0.0.1:80"},
+		{"[::1]:80", "tcp", "[::1]:80"},
+		{"unix:/run/app.sock", "unix", "/run/app.sock"},
+	}
+	for _, tt := range tests {
+		network, address := SplitNetwork(tt.addr)
+		if network != tt.network || address != tt.address {
+			t.Errorf("SplitNetwork(%q) = %q, %q; want %q, %q", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+
+	if got := JoinHostPort("unix:/run/app.sock", 80); got != "unix:/run/app.sock" {
+		t.Errorf("JoinHostPort of a socket = %q, want the socket alone", got)
+	}
+	if got := JoinHostPort("10.0.0.1", 80); got != "10.0.0.1:80" {
+		t.Errorf("JoinHostPort = %q", got)
+	}
+}
+
+// socketDir returns a directory short enough for socket paths, removed when
+// the test ends
+func socketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "lb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(socketDir(t), "lb.sock")
+
+	// A socket file left behind by a listener that didn't clean up
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("stale socket not left behind: %v", err)
+	}
+
+	ln, err := Listen(UnixPrefix + path)
+	if err != nil {
+		t.Fatalf("listening over a stale socket: %v", err)
+	}
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Write([]byte("hi"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := DialTimeout(UnixPrefix+path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hi" {
+		t.Errorf("read %q, %v over the socket", buf, err)
+	}
+	conn.Close()
+
+	// A live socket is not taken over
+	if _, err := Listen(UnixPrefix + path); err == nil {
+		t.Error("listening on a socket in use succeeded")
+	}
+
+	ln.Close()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file after close: %v, want it removed", err)
+	}
+}
+
+func TestListenLeavesOtherFiles(t *testing.T) {
+	path := filepath.Join(socketDir(t), "not-a-socket")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(UnixPrefix + path); err == nil {
+		t.Fatal("listening over a regular file succeeded")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("regular file became %q, %v", data, err)
+	}
+}
@@ -0,0 +1,38 @@
+package netutil
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// keepAliveConn is implemented by connections that support TCP keep-alive
+type keepAliveConn interface {
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(d time.Duration) error
+}
+
+// underlying unwraps TLS connections to reach the transport connection
+func underlying(conn net.Conn) net.Conn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return tlsConn.NetConn()
+	}
+	return conn
+}
+
+// SetKeepAlive enables TCP keep-alive with the given period. Connections
+// that don't support keep-alive (e.g. Unix sockets) are left unchanged.
+func SetKeepAlive(conn net.Conn, period time.Duration) error {
+	kc, ok := underlying(conn).(keepAliveConn)
+	if !ok {
+		return nil
+	}
+
+	if err := kc.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if period > 0 {
+		return kc.SetKeepAlivePeriod(period)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package netutil
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+// optConn records keep-alive settings
+type optConn struct {
+	net.Conn
+	keepAlive bool
+	period    time.Duration
+}
+
+func (c *optConn) SetKeepAlive(on bool) error               { c.keepAlive = on; return nil }
+func (c *optConn) SetKeepAlivePeriod(d time.Duration) error { c.period = d; return nil }
+
+func TestSetKeepAlive(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := &optConn{Conn: client}
+	if err := SetKeepAlive(conn, 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.keepAlive || conn.period != 15*time.Second {
+		t.Errorf("keep-alive = %t, period %v; want enabled with 15s", conn.keepAlive, conn.period)
+	}
+}
+
+func TestSetKeepAliveThroughTLS(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := &optConn{Conn: client}
+	if err := SetKeepAlive(tls.Client(conn, &tls.Config{}), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.keepAlive || conn.period != time.Minute {
+		t.Errorf("keep-alive under TLS = %t, period %v; want enabled with 1m", conn.keepAlive, conn.period)
+	}
+}
+
+func TestSetKeepAliveIgnoresOtherConns(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err := SetKeepAlive(client, time.Minute); err != nil {
+		t.Errorf("SetKeepAlive on a pipe: %v", err)
+	}
+}
+
+func TestSetKeepAliveOnTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := SetKeepAlive(conn, 30*time.Second); err != nil {
+		t.Errorf("SetKeepAlive on TCP: %v", err)
+	}
+}
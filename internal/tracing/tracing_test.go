@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ritikchawla/load-balancer/internal/config"
+)
+
+func TestDisabledIsNoop(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	_, span := Tracer().Start(context.Background(), "noop")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("span is recording with tracing disabled")
+	}
+}
+
+func TestInstallExportsSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := install(sdktrace.NewSimpleSpanProcessor(exporter))
+	defer shutdown(context.Background())
+
+	_, span := Tracer().Start(context.Background(), "handle_connection")
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "handle_connection" {
+		t.Fatalf("exported %v, want one handle_connection span", spans)
+	}
+	if got := spans[0].InstrumentationScope.Name; got != instrumentationName {
+		t.Errorf("instrumentation scope = %q, want %q", got, instrumentationName)
+	}
+}
+
+func TestHTTPPropagation(t *testing.T) {
+	shutdown := install(sdktrace.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()))
+	defer shutdown(context.Background())
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	in := http.Header{"Traceparent": {traceparent}}
+	ctx := ExtractHTTP(context.Background(), in)
+	if got := trace.SpanContextFromContext(ctx).TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("extracted trace ID %s", got)
+	}
+
+	ctx, span := Tracer().Start(ctx, "handle_request")
+	defer span.End()
+	out := http.Header{}
+	InjectHTTP(ctx, out)
+	got := out.Get("Traceparent")
+	if len(got) != len(traceparent) || got[3:35] != traceparent[3:35] || got == traceparent {
+		t.Errorf("injected traceparent %q, want a child span of %q", got, traceparent)
+	}
+}